@@ -0,0 +1,159 @@
+package gelf
+
+import (
+	"time"
+
+	"github.com/aphistic/golf"
+)
+
+// The bounds on the delay between attempts to reconnect to the server.
+const (
+	minBackoff = 100 * time.Millisecond
+	maxBackoff = 30 * time.Second
+)
+
+// client logs messages to a GELF server.
+type client interface {
+	// log logs the message at the glog severity, with the data as
+	// additional fields.
+	log(severity string, data map[string]interface{}, message string) error
+	close()
+}
+
+// dialer connects a client to the GELF server at the uri, which logs every
+// message with the attributes.
+type dialer func(uri string, attrs map[string]interface{}) (client, error)
+
+// golfClient is a client which logs messages with golf.
+type golfClient struct {
+	c *golf.Client
+	l *golf.Logger
+}
+
+// dialGolf connects a golf client to the GELF server at the uri.
+func dialGolf(uri string, attrs map[string]interface{}) (client, error) {
+	c, err := golf.NewClient()
+	if err != nil {
+		return nil, err
+	}
+	if err := c.Dial(uri); err != nil {
+		c.Close()
+		return nil, err
+	}
+	l, err := c.NewLogger()
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	for k, v := range attrs {
+		l.SetAttr(k, v)
+	}
+	return &golfClient{c: c, l: l}, nil
+}
+
+func (g *golfClient) log(severity string, data map[string]interface{}, message string) error {
+	switch severity {
+	case "INFO":
+		return g.l.Infom(data, "%s", message)
+	case "WARNING":
+		return g.l.Warnm(data, "%s", message)
+	case "ERROR":
+		return g.l.Errm(data, "%s", message)
+	case "FATAL":
+		return g.l.Critm(data, "%s", message)
+	}
+	return nil
+}
+
+func (g *golfClient) close() {
+	g.c.Close()
+}
+
+// conn is a connection to a GELF server, which is re-established (backing off
+// exponentially between attempts) when logging to it fails.
+type conn struct {
+	uri   string
+	attrs map[string]interface{}
+	dial  dialer
+	stats *Stats
+
+	c       client
+	backoff time.Duration
+	retryAt time.Time
+}
+
+// dial connects to the GELF server at the uri with the dialer.
+func dial(uri string, attrs map[string]interface{}, d dialer, stats *Stats) (*conn, error) {
+	c, err := d(uri, attrs)
+	if err != nil {
+		return nil, err
+	}
+	return &conn{uri: uri, attrs: attrs, dial: d, stats: stats, c: c}, nil
+}
+
+// send logs the message to the server, reconnecting first if the connection
+// was lost. If logging to an established connection fails, such as when the
+// server restarted, it reconnects and logs the message once more before
+// backing off. Messages sent while the connection is down are dropped.
+func (c *conn) send(severity string, data map[string]interface{}, message string) {
+	retry := true
+	if c.c == nil {
+		if time.Now().Before(c.retryAt) {
+			c.stats.drop()
+			return
+		}
+		if !c.redial() {
+			return
+		}
+		retry = false
+	}
+
+	err := c.c.log(severity, data, message)
+	if err != nil && retry {
+		c.c.close()
+		if !c.redial() {
+			return
+		}
+		err = c.c.log(severity, data, message)
+	}
+	if err != nil {
+		c.c.close()
+		c.c = nil
+		c.fail(err)
+	}
+}
+
+// redial connects to the server again, returning whether it succeeded.
+func (c *conn) redial() bool {
+	nc, err := c.dial(c.uri, c.attrs)
+	if err != nil {
+		c.c = nil
+		c.fail(err)
+		return false
+	}
+	c.c, c.backoff = nc, 0
+	c.stats.reconnected()
+	return true
+}
+
+// fail records the error and schedules the next attempt to reconnect.
+func (c *conn) fail(err error) {
+	c.stats.failed(err)
+	switch {
+	case c.backoff == 0:
+		c.backoff = minBackoff
+	case c.backoff < maxBackoff:
+		c.backoff *= 2
+		if c.backoff > maxBackoff {
+			c.backoff = maxBackoff
+		}
+	}
+	c.retryAt = time.Now().Add(c.backoff)
+}
+
+// close closes the connection to the server, if it is open.
+func (c *conn) close() {
+	if c.c != nil {
+		c.c.close()
+	}
+}
@@ -5,9 +5,11 @@ import (
 	"strings"
 	"time"
 
-	"github.com/aphistic/golf"
+	"github.com/getsentry/sentry-go"
 	"github.com/yext/glog"
+	"github.com/yext/glog-contrib/internal/convert"
 	"github.com/yext/glog-contrib/raven/stacktrace"
+	glogstacktrace "github.com/yext/glog-contrib/stacktrace"
 
 	"golang.org/x/time/rate"
 )
@@ -16,20 +18,24 @@ import (
 // Events sent at a higher rate than maxEventsPerSec will be ignored.
 // The uri must have a udp or tcp scheme.
 func Capture(attrs map[string]interface{}, serverUri string, maxEventsPerSec int, eventCh <-chan glog.Event) error {
-	c, _ := golf.NewClient()
-	defer c.Close()
+	return CaptureWithStats(attrs, serverUri, maxEventsPerSec, eventCh, new(Stats))
+}
 
-	if err := c.Dial(serverUri); err != nil {
-		return err
-	}
-	logger, err := c.NewLogger()
+// CaptureWithStats is like Capture, but also reports on the health of the
+// connection to the gelf server in stats. If logging to the server fails, it
+// reconnects and sends the event again. If that fails too, it backs off
+// exponentially between attempts to reconnect, and any events logged in the
+// meantime are dropped.
+func CaptureWithStats(attrs map[string]interface{}, serverUri string, maxEventsPerSec int, eventCh <-chan glog.Event, stats *Stats) error {
+	return capture(attrs, serverUri, maxEventsPerSec, eventCh, stats, dialGolf)
+}
+
+func capture(attrs map[string]interface{}, serverUri string, maxEventsPerSec int, eventCh <-chan glog.Event, stats *Stats, d dialer) error {
+	c, err := dial(serverUri, attrs, d, stats)
 	if err != nil {
 		return err
 	}
-
-	for k, v := range attrs {
-		logger.SetAttr(k, v)
-	}
+	defer c.close()
 
 	// Also use maxEventsPerSec as the burst size
 	var (
@@ -41,40 +47,45 @@ func Capture(attrs map[string]interface{}, serverUri string, maxEventsPerSec int
 			continue
 		}
 
-		logEvent(logger, e)
+		logEvent(c, e)
 	}
 	return nil
 }
 
-func logEvent(logger *golf.Logger, e glog.Event) {
-	data := map[string]interface{}{}
-	for _, d := range e.Data {
-		switch t := d.(type) {
-		case map[string]interface{}:
-			for k, v := range t {
-				data[k] = v
-			}
-		}
-	}
+func logEvent(c *conn, e glog.Event) {
+	glogData := convert.ExtractData(e.Data)
+	data := glogData.Fields
 
 	st := stacktrace.Build(e.StackTrace)
 	var frames []string
 	for _, frame := range st.Frames {
 		frames = append(frames, fmt.Sprintf("function %s at line %s", frame.Function, frame.LineNo))
 	}
+	// Follow the frames of the call site with those of each error logged, and
+	// of the errors it wraps, as the sentry backend does.
+	for _, err := range glogData.Errors {
+		if err == nil {
+			continue
+		}
+		if trace := glogstacktrace.ExtractChainStacktrace(err); trace != nil {
+			for _, frame := range trace.Frames {
+				frames = append(frames, fmt.Sprintf("function %s at line %d", qualifiedFunction(frame), frame.Lineno))
+			}
+		}
+	}
 	data["exceptionStackTrace"] = strings.Join(frames, ", ")
 
-	message := string(e.Message)
+	_, message := glogstacktrace.ParseGlogPrefix(e.Message)
 
 	data["levelName"] = e.Severity
-	switch e.Severity {
-	case "INFO":
-		logger.Infom(data, message)
-	case "WARNING":
-		logger.Warnm(data, message)
-	case "ERROR":
-		logger.Errm(data, message)
-	case "FATAL":
-		logger.Critm(data, message)
+	c.send(e.Severity, data, message)
+}
+
+// qualifiedFunction returns the function of the frame with its package, as
+// in the frames of the call site.
+func qualifiedFunction(frame sentry.Frame) string {
+	if frame.Module == "" || strings.HasPrefix(frame.Function, frame.Module+".") {
+		return frame.Function
 	}
+	return frame.Module + "." + frame.Function
 }
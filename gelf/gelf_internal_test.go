@@ -0,0 +1,215 @@
+package gelf
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yext/glog"
+	"github.com/yext/yerrors"
+)
+
+// message is a message logged to a fakeServer.
+type message struct {
+	severity string
+	fields   map[string]interface{}
+	text     string
+}
+
+// fakeServer stands in for a GELF server which may be stopped and restarted.
+// Clients dialed before a restart fail to log, as when their connection is
+// closed by the server.
+type fakeServer struct {
+	mu       sync.Mutex
+	up       bool
+	restarts int
+	messages []message
+}
+
+func (s *fakeServer) dial(uri string, attrs map[string]interface{}) (client, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.up {
+		return nil, errors.New("connection refused")
+	}
+	return &fakeClient{s: s, restarts: s.restarts, attrs: attrs}, nil
+}
+
+// start starts or restarts the server.
+func (s *fakeServer) start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.up = true
+	s.restarts++
+}
+
+func (s *fakeServer) stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.up = false
+}
+
+func (s *fakeServer) received() []message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]message(nil), s.messages...)
+}
+
+type fakeClient struct {
+	s        *fakeServer
+	restarts int
+	attrs    map[string]interface{}
+}
+
+func (c *fakeClient) log(severity string, data map[string]interface{}, text string) error {
+	c.s.mu.Lock()
+	defer c.s.mu.Unlock()
+	if !c.s.up || c.restarts != c.s.restarts {
+		return errors.New("broken pipe")
+	}
+	fields := map[string]interface{}{}
+	for k, v := range c.attrs {
+		fields[k] = v
+	}
+	for k, v := range data {
+		fields[k] = v
+	}
+	c.s.messages = append(c.s.messages, message{severity, fields, text})
+	return nil
+}
+
+func (c *fakeClient) close() {}
+
+// startCapture captures the events logged with the returned function to the
+// server, until the returned stop function is called.
+func startCapture(t *testing.T, s *fakeServer, attrs map[string]interface{}, stats *Stats) (func(glog.Event), func()) {
+	events := make(chan glog.Event)
+	done := make(chan error)
+	go func() {
+		done <- capture(attrs, "tcp://gelf.example.com", 1000, events, stats, s.dial)
+	}()
+	return func(e glog.Event) { events <- e }, func() {
+		close(events)
+		assert.NoError(t, <-done)
+	}
+}
+
+// logUntil logs events until cond is true.
+func logUntil(t *testing.T, log func(glog.Event), cond func() bool) {
+	deadline := time.Now().Add(10 * time.Second)
+	for !cond() {
+		require.True(t, time.Now().Before(deadline), "timed out")
+		log(glog.Event{Severity: "ERROR", Message: []byte("something failed")})
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestCaptureReconnects(t *testing.T) {
+	s := new(fakeServer)
+	s.start()
+	stats := new(Stats)
+	log, stop := startCapture(t, s, map[string]interface{}{"app": "test"}, stats)
+	defer stop()
+
+	logUntil(t, log, func() bool { return len(s.received()) > 0 })
+	msg := s.received()[0]
+	assert.Equal(t, "something failed", msg.text)
+	assert.Equal(t, "ERROR", msg.severity)
+	assert.Equal(t, "test", msg.fields["app"])
+	assert.Equal(t, Connected, stats.State())
+
+	// Drop the server, so that logging begins to fail.
+	s.stop()
+	logUntil(t, log, func() bool { return stats.State() == Disconnected })
+	assert.Error(t, stats.LastError())
+	assert.Equal(t, 0, stats.Reconnects())
+
+	// Restart the server, so that the connection is re-established.
+	s.start()
+	sent := len(s.received())
+	logUntil(t, log, func() bool { return len(s.received()) > sent })
+	assert.Equal(t, Connected, stats.State())
+	assert.Equal(t, 1, stats.Reconnects())
+	assert.Greater(t, stats.Dropped(), 0)
+}
+
+func TestCaptureRetriesAfterServerRestarts(t *testing.T) {
+	s := new(fakeServer)
+	s.start()
+	stats := new(Stats)
+	log, stop := startCapture(t, s, nil, stats)
+
+	// The server restarts between each message.
+	for i := 1; i <= 3; i++ {
+		log(glog.Event{Severity: "ERROR", Message: []byte("something failed")})
+		require.Eventually(t, func() bool { return len(s.received()) == i }, 10*time.Second, time.Millisecond)
+		s.start()
+	}
+	stop()
+
+	assert.Len(t, s.received(), 3)
+	assert.Equal(t, Connected, stats.State())
+	assert.Equal(t, 2, stats.Reconnects())
+	assert.Equal(t, 0, stats.Dropped(), "failed messages are retried on a new connection")
+}
+
+func TestBackoff(t *testing.T) {
+	s := new(fakeServer)
+	s.start()
+	stats := new(Stats)
+	c, err := dial("tcp://gelf.example.com", nil, s.dial, stats)
+	require.NoError(t, err)
+
+	s.stop()
+	c.send("ERROR", map[string]interface{}{}, "first")
+	assert.Equal(t, minBackoff, c.backoff)
+	c.send("ERROR", map[string]interface{}{}, "second")
+	assert.Equal(t, 2, stats.Dropped(), "messages are dropped while backing off")
+
+	for i := 0; i < 20; i++ {
+		c.retryAt = time.Time{}
+		c.send("ERROR", map[string]interface{}{}, "again")
+	}
+	assert.Equal(t, maxBackoff, c.backoff, "the backoff is bounded")
+
+	s.start()
+	c.retryAt = time.Time{}
+	c.send("ERROR", map[string]interface{}{}, "recovered")
+	assert.Equal(t, time.Duration(0), c.backoff, "the backoff is reset on reconnecting")
+	assert.Equal(t, Connected, stats.State())
+}
+
+func createError() (error, int) {
+	_, _, line, _ := runtime.Caller(0)
+	return yerrors.New("not found"), line + 1
+}
+
+func TestCaptureErrorStack(t *testing.T) {
+	s := new(fakeServer)
+	s.start()
+	log, stop := startCapture(t, s, nil, new(Stats))
+
+	cause, line := createError()
+	pcs := make([]uintptr, 10)
+	pcs = pcs[:runtime.Callers(1, pcs)]
+	log(glog.Event{
+		Severity:   "ERROR",
+		Message:    []byte("lookup failed"),
+		StackTrace: pcs,
+		Data:       []interface{}{glog.ErrorArg{Error: yerrors.Wrap(cause)}},
+	})
+	stop()
+
+	require.Len(t, s.received(), 1)
+	trace, _ := s.received()[0].fields["exceptionStackTrace"].(string)
+	assert.Contains(t, trace, "function github.com/yext/glog-contrib/gelf.TestCaptureErrorStack at line",
+		"the frames of the call site are included")
+	assert.True(t, strings.HasSuffix(trace, fmt.Sprintf("function github.com/yext/glog-contrib/gelf.createError at line %d", line)),
+		"the frames of the error follow, innermost last: %s", trace)
+}
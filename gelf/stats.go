@@ -0,0 +1,92 @@
+package gelf
+
+import "sync"
+
+// State is the state of the connection to the GELF server.
+type State int
+
+// The states of the connection to the GELF server.
+const (
+	// Connected means events are being logged to the server.
+	Connected State = iota
+	// Disconnected means the last attempt to log or dial failed, and events
+	// are dropped until the connection is re-established.
+	Disconnected
+)
+
+func (s State) String() string {
+	switch s {
+	case Connected:
+		return "connected"
+	case Disconnected:
+		return "disconnected"
+	}
+	return "unknown"
+}
+
+// Stats reports on the health of the connection to the GELF server. It is
+// updated by CaptureWithStats and may be read concurrently, such as to export
+// it as metrics.
+type Stats struct {
+	mu         sync.Mutex
+	state      State
+	reconnects int
+	lastErr    error
+	dropped    int
+}
+
+// State returns the current state of the connection.
+func (s *Stats) State() State {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state
+}
+
+// Reconnects returns the number of times the connection has been
+// re-established after being lost.
+func (s *Stats) Reconnects() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.reconnects
+}
+
+// LastError returns the most recent error logging to or dialing the server,
+// or nil if there has not been one.
+func (s *Stats) LastError() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastErr
+}
+
+// Dropped returns the number of events which were not sent because the
+// connection was down.
+func (s *Stats) Dropped() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dropped
+}
+
+// failed records an error logging to or dialing the server, which dropped an
+// event.
+func (s *Stats) failed(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state = Disconnected
+	s.lastErr = err
+	s.dropped++
+}
+
+// drop records an event dropped while waiting to reconnect.
+func (s *Stats) drop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dropped++
+}
+
+// reconnected records that the connection was re-established.
+func (s *Stats) reconnected() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state = Connected
+	s.reconnects++
+}
@@ -0,0 +1,48 @@
+package convert
+
+import (
+	"net/http"
+
+	"github.com/yext/glog"
+)
+
+// Data is the data passed to glog with a message, sorted into the types
+// which each backend handles the same way.
+type Data struct {
+	// Fields are the merged fields of each map[string]interface{}, with those
+	// of later maps overriding earlier ones. It is never nil, so that a
+	// backend can add its own fields.
+	Fields map[string]interface{}
+	// Errors are the errors of each glog.ErrorArg, in order.
+	Errors []error
+	// Format is the format string of the last glog.FormatStringArg, if any.
+	Format string
+	// Request is the last *http.Request, if any.
+	Request *http.Request
+	// Other is the rest of the data, in order, such as the attributes of a
+	// particular backend.
+	Other []interface{}
+}
+
+// ExtractData sorts the data of a glog event into its fields, errors, format
+// string and request, leaving the rest for the backend to handle.
+func ExtractData(data []interface{}) Data {
+	d := Data{Fields: map[string]interface{}{}}
+	for _, v := range data {
+		switch t := v.(type) {
+		case map[string]interface{}:
+			for k, v := range t {
+				d.Fields[k] = v
+			}
+		case glog.ErrorArg:
+			d.Errors = append(d.Errors, t.Error)
+		case glog.FormatStringArg:
+			d.Format = t.Format
+		case *http.Request:
+			d.Request = t
+		default:
+			d.Other = append(d.Other, v)
+		}
+	}
+	return d
+}
@@ -0,0 +1,38 @@
+package convert_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yext/glog"
+
+	"github.com/yext/glog-contrib/internal/convert"
+)
+
+func TestExtractData(t *testing.T) {
+	err1, err2 := errors.New("first"), errors.New("second")
+	req1, _ := http.NewRequest("GET", "http://example.com/1", nil)
+	req2, _ := http.NewRequest("GET", "http://example.com/2", nil)
+	type attribute string
+
+	d := convert.ExtractData([]interface{}{
+		map[string]interface{}{"a": 1, "b": 2},
+		glog.ErrorArg{Error: err1},
+		attribute("first"),
+		glog.FormatStringArg{Format: "failed: %v"},
+		req1,
+		map[string]interface{}{"b": 3},
+		glog.ErrorArg{Error: err2},
+		req2,
+		attribute("second"),
+	})
+	assert.Equal(t, map[string]interface{}{"a": 1, "b": 3}, d.Fields, "later fields override earlier ones")
+	assert.Equal(t, []error{err1, err2}, d.Errors)
+	assert.Equal(t, "failed: %v", d.Format)
+	assert.Equal(t, req2, d.Request)
+	assert.Equal(t, []interface{}{attribute("first"), attribute("second")}, d.Other)
+
+	assert.NotNil(t, convert.ExtractData(nil).Fields, "fields are never nil")
+}
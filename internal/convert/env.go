@@ -0,0 +1,20 @@
+package convert
+
+import "strings"
+
+// TagsFromEnv returns a tag for every environment variable beginning with the
+// prefix, named by the remainder of the variable (so that SENTRY_TAG_region=us
+// becomes the tag region=us, with a prefix of "SENTRY_TAG_"). Variables with
+// nothing after the prefix are skipped.
+func TagsFromEnv(prefix string, environ []string) map[string]string {
+	tags := map[string]string{}
+	for _, kv := range environ {
+		if !strings.HasPrefix(kv, prefix) {
+			continue
+		}
+		if eq := strings.Index(kv, "="); eq > len(prefix) {
+			tags[kv[len(prefix):eq]] = kv[eq+1:]
+		}
+	}
+	return tags
+}
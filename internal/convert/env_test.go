@@ -0,0 +1,16 @@
+package convert_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yext/glog-contrib/internal/convert"
+)
+
+func TestTagsFromEnv(t *testing.T) {
+	environ := []string{"SENTRY_TAG_region=us", "SENTRY_TAG_team=a=b", "SENTRY_TAG_=ignored", "HOME=/root"}
+
+	assert.Equal(t, map[string]string{"region": "us", "team": "a=b"}, convert.TagsFromEnv("SENTRY_TAG_", environ))
+	assert.Empty(t, convert.TagsFromEnv("OTHER_", environ))
+}
@@ -0,0 +1,18 @@
+package convert
+
+// WrappedError returns the error wrapped by err, as errors.Unwrap does, or the
+// first of the errors it wraps if it wraps several (as those returned by
+// errors.Join and by fmt.Errorf with several %w verbs do). It returns false
+// if err doesn't wrap errors.
+func WrappedError(err error) (error, bool) {
+	switch wrapper := err.(type) {
+	case interface{ Unwrap() error }:
+		return wrapper.Unwrap(), true
+	case interface{ Unwrap() []error }:
+		if errs := wrapper.Unwrap(); len(errs) > 0 {
+			return errs[0], true
+		}
+		return nil, true
+	}
+	return nil, false
+}
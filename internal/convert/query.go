@@ -0,0 +1,38 @@
+package convert
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// QueryString renders the query string with its keys sorted, and the values
+// of repeated keys joined with join, as the backends join those of headers.
+// Each value is rendered by value, which may mask sensitive parameters, or
+// escaped if value is nil. A query string which can't be parsed is returned
+// as it is.
+func QueryString(rawQuery string, join func([]string) string, value func(key, value string) string) string {
+	values, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return rawQuery
+	}
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		rendered := make([]string, len(values[k]))
+		for i, v := range values[k] {
+			if value != nil {
+				rendered[i] = value(k, v)
+			} else {
+				rendered[i] = url.QueryEscape(v)
+			}
+		}
+		parts = append(parts, url.QueryEscape(k)+"="+join(rendered))
+	}
+	return strings.Join(parts, "&")
+}
@@ -0,0 +1,25 @@
+package convert_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yext/glog-contrib/internal/convert"
+)
+
+func TestQueryString(t *testing.T) {
+	join := func(v []string) string { return strings.Join(v, ",") }
+
+	assert.Equal(t, "a=1&b=2,x+y", convert.QueryString("b=2&a=1&b=x+y", join, nil), "keys are sorted")
+	assert.Equal(t, "a=%;", convert.QueryString("a=%;", join, nil), "unparsable query strings are kept")
+
+	mask := func(k, v string) string {
+		if k == "token" {
+			return "***"
+		}
+		return v
+	}
+	assert.Equal(t, "page=2&token=***", convert.QueryString("token=secret&page=2", join, mask))
+}
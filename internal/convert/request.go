@@ -0,0 +1,69 @@
+// Package convert holds the helpers shared by the backends to convert the
+// data of glog events.
+package convert
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// RequestBody reads the body of the request, truncated to the limit (unless
+// it isn't positive). At most one byte more than the limit is read, so that
+// large or streaming uploads aren't held in memory.
+//
+// Events are converted on another goroutine than the one handling the
+// request, so the body the handler reads is never consumed or replaced. The
+// body is only captured if a copy of it can be read from GetBody (which is
+// set for requests created with a buffered body, such as by http.NewRequest
+// with a bytes.Reader), or if it is an io.ReadSeeker, which is read from the
+// start and then sought back to where it was. The bodies of incoming server
+// requests are neither, so are not captured unless the caller logs a request
+// with a buffered copy of the body.
+func RequestBody(r *http.Request, limit int) string {
+	if r.Body == nil || r.Body == http.NoBody {
+		return ""
+	}
+
+	var body io.Reader
+	switch {
+	case r.GetBody != nil:
+		rc, err := r.GetBody()
+		if err != nil {
+			return fmt.Sprintf("<%v>", err)
+		}
+		defer rc.Close()
+		body = rc
+	default:
+		s, ok := r.Body.(io.ReadSeeker)
+		if !ok {
+			return ""
+		}
+		pos, err := s.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return fmt.Sprintf("<%v>", err)
+		}
+		if _, err := s.Seek(0, io.SeekStart); err != nil {
+			return fmt.Sprintf("<%v>", err)
+		}
+		defer s.Seek(pos, io.SeekStart)
+		body = s
+	}
+
+	if limit > 0 {
+		body = io.LimitReader(body, int64(limit)+1)
+	}
+	b, err := ioutil.ReadAll(body)
+	if err != nil {
+		return fmt.Sprintf("<%v>", err)
+	}
+
+	if limit > 0 && len(b) > limit {
+		if r.ContentLength > int64(limit) {
+			return fmt.Sprintf("%s…(truncated %d bytes)", b[:limit], r.ContentLength-int64(limit))
+		}
+		return fmt.Sprintf("%s…(truncated)", b[:limit])
+	}
+	return string(b)
+}
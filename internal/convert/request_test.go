@@ -0,0 +1,83 @@
+package convert_test
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yext/glog-contrib/internal/convert"
+)
+
+// streamBody is a request body of unknown length which counts the bytes read
+// from it.
+type streamBody struct {
+	io.Reader
+	read int
+}
+
+func (b *streamBody) Read(p []byte) (int, error) {
+	n, err := b.Reader.Read(p)
+	b.read += n
+	return n, err
+}
+
+func (b *streamBody) Close() error { return nil }
+
+func TestRequestBodyStreaming(t *testing.T) {
+	body := &streamBody{Reader: strings.NewReader("body")}
+	req := httptest.NewRequest("POST", "/upload", nil)
+	req.Body, req.ContentLength = body, -1
+
+	assert.Equal(t, "", convert.RequestBody(req, 4), "a body which can't be read again isn't captured")
+	assert.Equal(t, 0, body.read, "the body is left for the handler")
+	assert.Same(t, body, req.Body, "the request isn't modified")
+}
+
+func TestRequestBodyBuffered(t *testing.T) {
+	content := strings.Repeat("a", 1<<20)
+	req, err := http.NewRequest("POST", "/upload", strings.NewReader(content))
+	require.NoError(t, err)
+	body := req.Body
+
+	assert.Equal(t, fmt.Sprintf("aaaa…(truncated %d bytes)", len(content)-4), convert.RequestBody(req, 4))
+	assert.Equal(t, body, req.Body, "the request isn't modified")
+	b, err := ioutil.ReadAll(req.Body)
+	require.NoError(t, err)
+	assert.Equal(t, content, string(b), "the entire body can be read after capture")
+}
+
+// seekBody is a request body which can be sought.
+type seekBody struct {
+	*strings.Reader
+}
+
+func (seekBody) Close() error { return nil }
+
+func TestRequestBodySeekable(t *testing.T) {
+	req := httptest.NewRequest("POST", "/upload", nil)
+	req.Body = seekBody{strings.NewReader("body")}
+	p := make([]byte, 2)
+	_, err := req.Body.Read(p)
+	require.NoError(t, err)
+
+	assert.Equal(t, "body", convert.RequestBody(req, 0), "the body is read from the start")
+	b, err := ioutil.ReadAll(req.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "dy", string(b), "the body is sought back to where it was")
+}
+
+func TestRequestBodyUnlimited(t *testing.T) {
+	req, err := http.NewRequest("POST", "/upload", strings.NewReader("body"))
+	require.NoError(t, err)
+	assert.Equal(t, "body", convert.RequestBody(req, 0))
+	b, err := ioutil.ReadAll(req.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "body", string(b))
+}
@@ -0,0 +1,169 @@
+// The multiplex package fans a single glog event channel out to several
+// backends (for example sentry, raven and gelf), isolating them from one
+// another. Each backend receives events on its own bounded buffer which is
+// drained by its own goroutine, so a backend that is slow or failing cannot
+// stall the shared glog channel or any of the other backends.
+//
+//	m := multiplex.New(
+//		multiplex.Backend{
+//			Name:   "sentry",
+//			Buffer: 100,
+//			Policy: multiplex.DropNewest,
+//			Run: func(ch <-chan glog.Event) {
+//				sentry.CaptureErrors("project", dsns, opts, ch)
+//			},
+//		},
+//		multiplex.Backend{
+//			Name: "gelf",
+//			Run: func(ch <-chan glog.Event) {
+//				gelf.Capture(attrs, uri, 100, ch)
+//			},
+//		})
+//	go m.Run(glog.RegisterBackend())
+package multiplex
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/yext/glog"
+)
+
+// The buffer size used for a backend which does not specify one.
+const defaultBuffer = 100
+
+// DropPolicy determines which event is discarded when a backend's
+// buffer is full.
+type DropPolicy int
+
+const (
+	// DropNewest discards the incoming event, keeping the events
+	// already buffered for the backend. This is the default.
+	DropNewest DropPolicy = iota
+	// DropOldest discards the oldest buffered event to make room
+	// for the incoming one, so the backend always sees the most
+	// recent events.
+	DropOldest
+)
+
+// Backend describes a consumer of glog events.
+type Backend struct {
+	// Name identifies the backend in Stats.
+	Name string
+	// Buffer is the number of events which may be queued for the
+	// backend before its Policy applies. Defaults to 100.
+	Buffer int
+	// Policy is applied when the buffer is full.
+	Policy DropPolicy
+	// Run consumes events from the channel until it is closed. It is
+	// invoked in its own goroutine.
+	Run func(<-chan glog.Event)
+}
+
+// Stats contains the delivery counters for a single backend.
+type Stats struct {
+	// Sent is the number of events queued for the backend, excluding
+	// those later discarded to make room for newer events.
+	Sent uint64
+	// Dropped is the number of events discarded due to a full buffer.
+	Dropped uint64
+}
+
+type backend struct {
+	Backend
+	ch      chan glog.Event
+	sent    uint64
+	dropped uint64
+}
+
+// Multiplexer distributes glog events to a set of isolated backends.
+type Multiplexer struct {
+	backends []*backend
+	wg       sync.WaitGroup
+}
+
+// New constructs a Multiplexer for the given backends. The backends
+// are not started until Run is called.
+func New(backends ...Backend) *Multiplexer {
+	m := &Multiplexer{}
+	for _, b := range backends {
+		if b.Buffer <= 0 {
+			b.Buffer = defaultBuffer
+		}
+		m.backends = append(m.backends, &backend{
+			Backend: b,
+			ch:      make(chan glog.Event, b.Buffer),
+		})
+	}
+	return m
+}
+
+// Run starts each backend and forwards every event received on comm to
+// all of them. It never blocks on a backend: events which do not fit in
+// a backend's buffer are dropped according to its Policy. When comm is
+// closed, Run closes each backend's channel and waits for the backends
+// to return.
+func (m *Multiplexer) Run(comm <-chan glog.Event) {
+	for _, b := range m.backends {
+		m.wg.Add(1)
+		go func(b *backend) {
+			defer m.wg.Done()
+			b.Run(b.ch)
+		}(b)
+	}
+
+	for e := range comm {
+		for _, b := range m.backends {
+			b.offer(e)
+		}
+	}
+
+	for _, b := range m.backends {
+		close(b.ch)
+	}
+	m.wg.Wait()
+}
+
+// Stats returns the current counters for each backend, keyed by name.
+func (m *Multiplexer) Stats() map[string]Stats {
+	r := make(map[string]Stats, len(m.backends))
+	for _, b := range m.backends {
+		r[b.Name] = Stats{
+			Sent:    atomic.LoadUint64(&b.sent),
+			Dropped: atomic.LoadUint64(&b.dropped),
+		}
+	}
+	return r
+}
+
+// offer queues the event for the backend without blocking.
+func (b *backend) offer(e glog.Event) {
+	select {
+	case b.ch <- e:
+		atomic.AddUint64(&b.sent, 1)
+		return
+	default:
+	}
+
+	if b.Policy == DropNewest {
+		atomic.AddUint64(&b.dropped, 1)
+		return
+	}
+
+	// Make room by discarding the oldest buffered event. The backend may
+	// be draining concurrently, so either receive may find the buffer
+	// already has space.
+	select {
+	case <-b.ch:
+		// The discarded event was counted as sent when it was queued.
+		atomic.AddUint64(&b.sent, ^uint64(0))
+		atomic.AddUint64(&b.dropped, 1)
+	default:
+	}
+	select {
+	case b.ch <- e:
+		atomic.AddUint64(&b.sent, 1)
+	default:
+		atomic.AddUint64(&b.dropped, 1)
+	}
+}
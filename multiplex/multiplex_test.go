@@ -0,0 +1,101 @@
+package multiplex_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yext/glog"
+	"github.com/yext/glog-contrib/multiplex"
+)
+
+func TestStalledBackendDoesNotBlockOthers(t *testing.T) {
+	const count = 50
+
+	stall := make(chan struct{})
+	received := make(chan glog.Event, count)
+	m := multiplex.New(
+		multiplex.Backend{
+			Name:   "stalled",
+			Buffer: 5,
+			Run: func(ch <-chan glog.Event) {
+				<-stall
+				for range ch {
+				}
+			},
+		},
+		multiplex.Backend{
+			Name:   "healthy",
+			Buffer: 5,
+			Run: func(ch <-chan glog.Event) {
+				for e := range ch {
+					received <- e
+				}
+			},
+		})
+
+	comm := make(chan glog.Event)
+	done := make(chan struct{})
+	go func() {
+		m.Run(comm)
+		close(done)
+	}()
+
+	for i := 0; i < count; i++ {
+		select {
+		case comm <- glog.Event{Severity: "ERROR"}:
+		case <-time.After(time.Second):
+			t.Fatalf("glog channel blocked after %d events", i)
+		}
+		select {
+		case <-received:
+		case <-time.After(time.Second):
+			t.Fatalf("healthy backend did not receive event %d", i)
+		}
+	}
+
+	stats := m.Stats()
+	assert.Equal(t, multiplex.Stats{Sent: count}, stats["healthy"])
+	assert.Equal(t, uint64(5), stats["stalled"].Sent, "stalled backend buffer is filled")
+	assert.Equal(t, uint64(count-5), stats["stalled"].Dropped, "overflow is dropped")
+
+	close(stall)
+	close(comm)
+	<-done
+}
+
+func TestDropOldest(t *testing.T) {
+	stall := make(chan struct{})
+	var received []string
+	m := multiplex.New(multiplex.Backend{
+		Name:   "backend",
+		Buffer: 2,
+		Policy: multiplex.DropOldest,
+		Run: func(ch <-chan glog.Event) {
+			<-stall
+			for e := range ch {
+				received = append(received, string(e.Message))
+			}
+		},
+	})
+
+	comm := make(chan glog.Event)
+	done := make(chan struct{})
+	go func() {
+		m.Run(comm)
+		close(done)
+	}()
+	for _, msg := range []string{"first", "second", "third"} {
+		comm <- glog.Event{Message: []byte(msg)}
+	}
+	assert.Eventually(t, func() bool {
+		return m.Stats()["backend"] == multiplex.Stats{Sent: 2, Dropped: 1}
+	}, time.Second, time.Millisecond)
+	close(stall)
+	close(comm)
+	<-done
+
+	assert.Equal(t, []string{"second", "third"}, received, "oldest event is dropped")
+	assert.Equal(t, multiplex.Stats{Sent: 2, Dropped: 1}, m.Stats()["backend"],
+		"each event is counted as either sent or dropped")
+}
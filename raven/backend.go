@@ -1,7 +1,9 @@
 package raven
 
 import (
-	"net/http"
+	"context"
+	"errors"
+	"log"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -9,7 +11,9 @@ import (
 	"strings"
 
 	"github.com/yext/glog"
+	"github.com/yext/glog-contrib/internal/convert"
 	"github.com/yext/glog-contrib/raven/stacktrace"
+	glogstacktrace "github.com/yext/glog-contrib/stacktrace"
 	"golang.org/x/xerrors"
 )
 
@@ -31,17 +35,21 @@ func init() {
 // sent to sentry they are tagged as coming from the given
 // project. It then sets up the connection to sentry and begins
 // to send any errors recieved over comm to sentry.
+// The client is configured by any options provided.
 // It panics if a client could not be initialized.
-func CaptureErrors(project, dsn string, comm <-chan glog.Event) {
+func CaptureErrors(project, dsn string, comm <-chan glog.Event, options ...Option) {
 	projectName = project
-	client, err := NewClient(dsn)
+	client, err := newClient(dsn, options)
 	if err != nil {
 		panic(err)
 	}
 
 	for glogEve := range comm {
 		if glogEve.Severity == "ERROR" {
-			client.CaptureGlogEvent(glogEve)
+			func() {
+				defer recoverCapture()
+				client.CaptureGlogEvent(glogEve)
+			}()
 		}
 	}
 }
@@ -54,18 +62,30 @@ func CaptureErrors(project, dsn string, comm <-chan glog.Event) {
 //
 // If the dsn of an event is not specified or is not equal to any of the
 // dsns arg, the dsn target will be assumed to be the first dsn in the dsns list.
-func CaptureErrorsAltDsn(project string, dsns []string, comm <-chan glog.Event) {
+// Each client is configured by any options provided.
+func CaptureErrorsAltDsn(project string, dsns []string, comm <-chan glog.Event, options ...Option) {
+	if err := CaptureErrorsWithContext(context.Background(), project, dsns, comm, options...); err != nil {
+		panic(err)
+	}
+}
+
+// CaptureErrorsWithContext is like CaptureErrorsAltDsn, but returns an error
+// rather than panicking if a client could not be initialized, and also stops
+// capturing errors when the context is done, so that capturing may be
+// restarted (such as with other DSNs). Any events already waiting in the glog
+// channel are captured before it returns.
+func CaptureErrorsWithContext(ctx context.Context, project string, dsns []string, comm <-chan glog.Event, options ...Option) error {
 	if len(dsns) == 0 {
-		panic("must specify at least one dsn")
+		return errors.New("must specify at least one dsn")
 	}
 	projectName = project
 
 	var primaryClient *Client
 	dsnClients := make(map[string]*Client)
 	for _, dsn := range dsns {
-		client, err := NewClient(dsn)
+		client, err := newClient(dsn, options)
 		if err != nil {
-			panic(err)
+			return err
 		}
 		if primaryClient == nil {
 			primaryClient = client
@@ -73,8 +93,9 @@ func CaptureErrorsAltDsn(project string, dsns []string, comm <-chan glog.Event)
 		dsnClients[dsn] = client
 	}
 
-	for glogEve := range comm {
+	capture := func(glogEve glog.Event) {
 		if glogEve.Severity == "ERROR" {
+			defer recoverCapture()
 			e := fromGlogEvent(glogEve)
 			eventDsnTarget := e.TargetDsn
 			if client, ok := dsnClients[eventDsnTarget]; ok {
@@ -84,14 +105,54 @@ func CaptureErrorsAltDsn(project string, dsns []string, comm <-chan glog.Event)
 			}
 		}
 	}
+	for {
+		select {
+		case glogEve, ok := <-comm:
+			if !ok {
+				return nil
+			}
+			capture(glogEve)
+		case <-ctx.Done():
+			// Capture the events logged immediately before stopping.
+			for {
+				select {
+				case glogEve, ok := <-comm:
+					if !ok {
+						return nil
+					}
+					capture(glogEve)
+				default:
+					return nil
+				}
+			}
+		}
+	}
+}
+
+// newClient creates a client for the dsn, configured by the options.
+func newClient(dsn string, options []Option) (*Client, error) {
+	client, err := NewClient(dsn)
+	if err != nil {
+		return nil, err
+	}
+	for _, opt := range options {
+		opt(client)
+	}
+	return client, nil
+}
+
+// recoverCapture recovers from a panic while capturing an event, so that the
+// capture loop continues with the next event.
+func recoverCapture() {
+	if r := recover(); r != nil {
+		// Don't use glog, or we'll just end up in an infinite loop
+		log.Printf("Recovered from panic sending error to Sentry: %v", r)
+	}
 }
 
 // fromGlogEvent converts a glog.Event to the format expected by Sentry.
 func fromGlogEvent(e glog.Event) *Event {
-	message := string(e.Message)
-	if square := strings.Index(message, "] "); square != -1 {
-		message = message[square+2:]
-	}
+	_, message := glogstacktrace.ParseGlogPrefix(e.Message)
 
 	logtrace := stacktrace.Build(e.StackTrace)
 	eve := &Event{
@@ -106,34 +167,38 @@ func fromGlogEvent(e glog.Event) *Event {
 		Logger:     os.Args[0],
 	}
 
-	data := map[string]interface{}{}
-	for _, d := range e.Data {
-		switch t := d.(type) {
+	glogData := convert.ExtractData(e.Data)
+	for _, d := range glogData.Other {
+		switch d.(type) {
 		case altDsn:
 			eve.TargetDsn = string(d.(altDsn))
 		case fingerprint:
 			eve.Fingerprint = []string(d.(fingerprint))
-		case *http.Request:
-			eve.Http = NewHttp(t)
-		case map[string]interface{}:
-			for k, v := range t {
-				data[k] = v
-			}
-		case glog.ErrorArg:
-			// Prepend the Message with the innermost error message.
-			// This causes it to be used for the headline.
-			eve.Message = headline(t.Error) + "\n\n" + message
-
-			// Augment the stack trace of the call site with the stack trace in
-			// the error.
-			eve.StackTrace = getXErrorStackTrace(eve.StackTrace, t.Error)
 		default:
 			//TODO(ltacon): ignore for now...
 		}
 	}
+	if glogData.Request != nil {
+		eve.Http = NewHttp(glogData.Request)
+	}
+	for _, err := range glogData.Errors {
+		// Prepend the Message with the innermost error message.
+		// This causes it to be used for the headline.
+		eve.Message = headline(err) + "\n\n" + message
+
+		// Augment the stack trace of the call site with the stack trace in
+		// the error.
+		eve.StackTrace = getXErrorStackTrace(eve.StackTrace, err)
+	}
+	data := glogData.Fields
 
 	// By default, set the fingerprint based on the stack trace.
 	// Sentry is supposed to do that by default, but it does not appear to work.
+	// The in-app frames of the glog invocation are used, as by the sentry
+	// backend, so that both group the same event the same way.
+	if len(eve.Fingerprint) == 0 {
+		eve.Fingerprint = glogstacktrace.Fingerprint(glogstacktrace.ExtractFrames(e.StackTrace, nil))
+	}
 	if len(eve.Fingerprint) == 0 {
 		eve.Fingerprint = eve.StackTrace.Strings()
 	}
@@ -165,12 +230,15 @@ func headline(err error) string {
 	// This provides context on the error, since returned errors are often constants.
 	var prev error
 	for {
-		wrapper, ok := err.(xerrors.Wrapper)
+		next, ok := convert.WrappedError(err)
 		if !ok {
 			break
 		}
 		prev = err
-		err = wrapper.Unwrap()
+		if next == nil {
+			break
+		}
+		err = next
 	}
 	if prev != nil {
 		return prev.Error()
@@ -196,15 +264,23 @@ func getXErrorStackTrace(callSite stacktrace.StackTrace, err error) stacktrace.S
 	return xs.trace
 }
 
+// The formats used by xerrors.Frame to write the function and location of a frame.
+const (
+	functionFormat = "%s\n    "
+	locationFormat = "%s:%d\n"
+)
+
 // xerrorsStack implements xerrors.Printer to capture only the wrapped stack trace.
 //
-// Exploits the fact that xerrors.Frame is always written as detail (and nothing else is, for any
-// known implementation).
+// Exploits the fact that xerrors.Frame is always written as detail, using a fixed pair of
+// format strings.
 //
 // It expects a sequence of alternating calls like this:
 //
 //   Printf("%s\n    ", []interface {}{"package.FuncName"})
 //   Printf("%s:%d\n", []interface {}{"/absolute/path/to/file.go", 47})
+//
+// interleaved with any other detail written by the error.
 type xerrorsStack struct {
 	detail bool
 	trace  stacktrace.StackTrace
@@ -214,21 +290,23 @@ type xerrorsStack struct {
 func (x *xerrorsStack) Print(args ...interface{}) {}
 
 func (x *xerrorsStack) Printf(format string, args ...interface{}) {
-	if x.detail {
-		switch len(args) {
-		case 1:
-			if fn, ok := args[0].(string); ok {
-				x.fnName = fn
-			}
-		case 2:
-			var (
-				absPath, ok1 = args[0].(string)
-				lineno, ok2  = args[1].(int)
-			)
-			if !ok1 || !ok2 {
-				glog.Warningf("unexpected: Printf(%q, %#v)", format, args)
-				return
-			}
+	if !x.detail {
+		return
+	}
+	// Only the function and location written by xerrors.Frame are used. Any
+	// other detail (such as a custom message written by the FormatError method
+	// of an error) is ignored.
+	switch {
+	case format == functionFormat && len(args) == 1:
+		if fn, ok := args[0].(string); ok {
+			x.fnName = fn
+		}
+	case format == locationFormat && len(args) == 2:
+		var (
+			absPath, ok1 = args[0].(string)
+			lineno, ok2  = args[1].(int)
+		)
+		if ok1 && ok2 {
 			x.trace.Frames = append(x.trace.Frames, stacktrace.StackFrame{
 				AbsPath:  absPath,
 				Filename: gopathRelativeFile(absPath),
@@ -236,6 +314,7 @@ func (x *xerrorsStack) Printf(format string, args ...interface{}) {
 				LineNo:   strconv.Itoa(lineno),
 			})
 		}
+		x.fnName = ""
 	}
 }
 
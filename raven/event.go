@@ -1,13 +1,12 @@
 package raven
 
 import (
-	"fmt"
-	"io"
-	"io/ioutil"
 	"net/http"
 	"runtime"
 	"strings"
+	"sync"
 
+	"github.com/yext/glog-contrib/internal/convert"
 	"github.com/yext/glog-contrib/raven/stacktrace"
 )
 
@@ -35,8 +34,8 @@ func NewHttp(req *http.Request) *Http {
 		Method:      req.Method,
 		Headers:     sentryHeaders(req.Header),
 		Cookies:     req.Header.Get("Cookie"),
-		QueryString: req.URL.RawQuery,
-		Data:        sentryData(req.Body),
+		QueryString: convert.QueryString(req.URL.RawQuery, joinValues, nil),
+		Data:        convert.RequestBody(req, getMaxBodySize()),
 	}
 }
 
@@ -60,19 +59,57 @@ func sentryHeaders(headers map[string][]string) map[string]string {
 	for k, v := range headers {
 		// Skip including cookies in the headers.  Cookies have their own section.
 		if k != "Cookie" {
-			m[k] = strings.Join(v, ",")
+			m[k] = joinValues(v)
 		}
 	}
 	return m
 }
 
-func sentryData(body io.ReadCloser) string {
-	if s, ok := body.(io.Seeker); ok {
-		s.Seek(0, 0)
-	}
-	b, err := ioutil.ReadAll(body)
-	if err != nil {
-		return fmt.Sprintf("<%v>", err)
-	}
-	return string(b)
+var (
+	valueSeparatorMu sync.RWMutex
+	valueSeparator   = ","
+)
+
+// SetValueSeparator sets the separator used to join the values of repeated
+// headers and query string parameters of a http.Request, such as "," (the
+// default) or "\n".
+func SetValueSeparator(sep string) {
+	valueSeparatorMu.Lock()
+	defer valueSeparatorMu.Unlock()
+	valueSeparator = sep
+}
+
+func joinValues(v []string) string {
+	valueSeparatorMu.RLock()
+	defer valueSeparatorMu.RUnlock()
+	return strings.Join(v, valueSeparator)
+}
+
+// The default maximum size of the request bodies captured.
+const defaultMaxBodySize = 4 << 10
+
+var (
+	maxBodySizeMu sync.RWMutex
+	maxBodySize   = defaultMaxBodySize
+)
+
+// SetMaxBodySize sets the maximum number of bytes of the body of a
+// http.Request which are captured, which defaults to 4KB. Longer bodies are
+// truncated, with a marker noting the number of bytes omitted. A size of zero
+// or less captures the entire body.
+//
+// A body is only captured if it can be read without consuming it, as when
+// the request has GetBody set or its body is an io.ReadSeeker. The bodies of
+// requests received by a server are not, so to capture one, log a copy of the
+// request with a buffered body.
+func SetMaxBodySize(size int) {
+	maxBodySizeMu.Lock()
+	defer maxBodySizeMu.Unlock()
+	maxBodySize = size
+}
+
+func getMaxBodySize() int {
+	maxBodySizeMu.RLock()
+	defer maxBodySizeMu.RUnlock()
+	return maxBodySize
 }
@@ -0,0 +1,65 @@
+package raven_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yext/glog-contrib/raven"
+)
+
+func TestNewHttpRepeatedValues(t *testing.T) {
+	req := httptest.NewRequest("GET", "/path?b=2&a=1&b=x+y", nil)
+	req.Header.Add("Accept", "text/html")
+	req.Header.Add("Accept", "application/json")
+
+	h := raven.NewHttp(req)
+	assert.Equal(t, "text/html,application/json", h.Headers["Accept"])
+	assert.Equal(t, "a=1&b=2,x+y", h.QueryString, "repeated parameters are joined like headers")
+
+	raven.SetValueSeparator("\n")
+	defer raven.SetValueSeparator(",")
+
+	h = raven.NewHttp(req)
+	assert.Equal(t, "text/html\napplication/json", h.Headers["Accept"])
+	assert.Equal(t, "a=1&b=2\nx+y", h.QueryString)
+}
+
+// bufferedRequest returns a request with the body, which may be read again
+// without consuming it.
+func bufferedRequest(t *testing.T, body string) *http.Request {
+	req, err := http.NewRequest("POST", "/upload", strings.NewReader(body))
+	require.NoError(t, err)
+	return req
+}
+
+func TestNewHttpMaxBodySize(t *testing.T) {
+	body := strings.Repeat("a", 5000)
+	h := raven.NewHttp(bufferedRequest(t, body))
+	assert.Equal(t, strings.Repeat("a", 4096)+"…(truncated 904 bytes)", h.Data, "bodies are truncated to 4KB by default")
+
+	h = raven.NewHttp(bufferedRequest(t, "small"))
+	assert.Equal(t, "small", h.Data)
+
+	raven.SetMaxBodySize(0)
+	defer raven.SetMaxBodySize(4096)
+	h = raven.NewHttp(bufferedRequest(t, body))
+	assert.Equal(t, body, h.Data, "entire body is captured without a limit")
+}
+
+func TestNewHttpBodyNotConsumed(t *testing.T) {
+	req := bufferedRequest(t, "body")
+	h := raven.NewHttp(req)
+	assert.Equal(t, "body", h.Data)
+
+	b, err := ioutil.ReadAll(req.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "body", string(b), "the body can be read after capture")
+
+	h = raven.NewHttp(httptest.NewRequest("POST", "/upload", strings.NewReader("body")))
+	assert.Equal(t, "", h.Data, "the body of a server request isn't captured")
+}
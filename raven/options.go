@@ -0,0 +1,34 @@
+package raven
+
+import (
+	"context"
+	"net"
+)
+
+// Option configures the clients created by CaptureErrors, in the same way as
+// the method of Client of the same name.
+type Option func(*Client)
+
+// WithDialer sets the function used by each client to open connections to the
+// Sentry server. See Client.SetDialer.
+func WithDialer(dial func(ctx context.Context, network, addr string) (net.Conn, error)) Option {
+	return func(c *Client) {
+		c.SetDialer(dial)
+	}
+}
+
+// WithGzip sets whether each client sends events as gzip compressed JSON. See
+// Client.SetGzip.
+func WithGzip(enabled bool) Option {
+	return func(c *Client) {
+		c.SetGzip(enabled)
+	}
+}
+
+// WithTagsFromEnv adds a default tag to each client for every environment
+// variable beginning with the prefix. See Client.AddTagsFromEnv.
+func WithTagsFromEnv(prefix string) Option {
+	return func(c *Client) {
+		c.AddTagsFromEnv(prefix)
+	}
+}
@@ -0,0 +1,281 @@
+package raven_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yext/glog"
+	"github.com/yext/glog-contrib/raven"
+	"github.com/yext/glog-contrib/raven/raventest"
+	"github.com/yext/glog-contrib/sentry"
+)
+
+func TestCapture(t *testing.T) {
+	server := raventest.NewServer()
+	defer server.Close()
+
+	client, err := raven.NewClient(server.DSN())
+	require.NoError(t, err)
+
+	id, err := client.CaptureMessage("test", "message")
+	require.NoError(t, err)
+
+	events := server.Events()
+	require.Len(t, events, 1)
+	assert.Equal(t, id, events[0].EventId)
+	assert.Equal(t, "test message", events[0].Message)
+	assert.Equal(t, raventest.Project, events[0].Project)
+	assert.Equal(t, "error", events[0].Level)
+	assert.Equal(t, "root", events[0].Logger)
+}
+
+func TestCaptureInvalidKey(t *testing.T) {
+	server := raventest.NewServer()
+	defer server.Close()
+
+	client, err := raven.NewClient(strings.Replace(server.DSN(), raventest.PublicKey+":", "other:", 1))
+	require.NoError(t, err)
+
+	assert.Error(t, client.Capture(&raven.Event{Message: "test"}), "server rejects the auth header")
+	assert.Empty(t, server.Events())
+}
+
+func TestCaptureTimestamp(t *testing.T) {
+	server := raventest.NewServer()
+	defer server.Close()
+
+	client, err := raven.NewClient(server.DSN())
+	require.NoError(t, err)
+
+	require.NoError(t, client.Capture(&raven.Event{Message: "first"}))
+	require.NoError(t, client.Capture(&raven.Event{Message: "legacy", Timestamp: "2020-01-02T03:04:05"}))
+	received := server.Events()
+	require.Len(t, received, 2)
+
+	assert.Regexp(t, regexp.MustCompile(`^\d{4}-\d\d-\d\dT\d\d:\d\d:\d\d\.\d{3}Z$`), received[0].Timestamp,
+		"timestamp is RFC3339 in UTC with millisecond precision")
+	ts, err := time.Parse(time.RFC3339Nano, received[0].Timestamp)
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), ts, time.Minute)
+
+	assert.Equal(t, "2020-01-02T03:04:05", received[1].Timestamp, "legacy timestamps are still accepted")
+}
+
+func TestCaptureUnixSocket(t *testing.T) {
+	server := raventest.NewServer()
+	defer server.Close()
+
+	socket := filepath.Join(t.TempDir(), "sentry.sock")
+	listener, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	defer listener.Close()
+	go server.Config.Serve(listener)
+
+	// The host in the DSN is unreachable, so events can only be
+	// delivered through the socket.
+	client, err := raven.NewClient(strings.Replace(server.DSN(), server.Listener.Addr().String(), "sentry.invalid", 1))
+	require.NoError(t, err)
+	client.SetDialer(func(ctx context.Context, _, _ string) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, "unix", socket)
+	})
+
+	require.NoError(t, client.Capture(&raven.Event{Message: "test"}))
+	events := server.Events()
+	require.Len(t, events, 1)
+	assert.Equal(t, "test", events[0].Message)
+}
+
+func TestAddTagsFromEnv(t *testing.T) {
+	t.Setenv("SENTRY_TAG_region", "us")
+	t.Setenv("SENTRY_TAG_job_name", "env")
+	t.Setenv("SENTRY_TAG_", "ignored")
+
+	server := raventest.NewServer()
+	defer server.Close()
+
+	client, err := raven.NewClient(server.DSN())
+	require.NoError(t, err)
+	client.AddTagsFromEnv("SENTRY_TAG_")
+
+	require.NoError(t, client.Capture(&raven.Event{Message: "test", Tags: map[string]string{"region": "eu"}}))
+	require.NoError(t, client.Capture(&raven.Event{Message: "test"}))
+	events := server.Events()
+	require.Len(t, events, 2)
+	assert.Equal(t, "eu", events[0].Tags["region"], "event tags take precedence")
+	assert.Equal(t, "env", events[0].Tags["job_name"])
+	assert.Equal(t, "us", events[1].Tags["region"])
+	assert.NotContains(t, events[1].Tags, "")
+}
+
+func TestCaptureErrorsOptions(t *testing.T) {
+	t.Setenv("SENTRY_TAG_region", "us")
+
+	server := raventest.NewServer()
+	defer server.Close()
+
+	// Events are only delivered through the socket, which records how
+	// they are encoded.
+	socket := filepath.Join(t.TempDir(), "sentry.sock")
+	listener, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	defer listener.Close()
+	encodings := make(chan string, 1)
+	go http.Serve(listener, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encodings <- r.Header.Get("Content-Encoding")
+		server.Config.Handler.ServeHTTP(w, r)
+	}))
+
+	comm := make(chan glog.Event, 1)
+	comm <- glog.Event{Severity: "ERROR", Message: []byte("test message")}
+	close(comm)
+	err = raven.CaptureErrorsWithContext(context.Background(), "example",
+		[]string{strings.Replace(server.DSN(), server.Listener.Addr().String(), "sentry.invalid", 1)}, comm,
+		raven.WithDialer(func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		}),
+		raven.WithGzip(true),
+		raven.WithTagsFromEnv("SENTRY_TAG_"))
+
+	require.NoError(t, err)
+	events := server.Events()
+	require.Len(t, events, 1, "the event is sent with the dialer")
+	assert.Equal(t, "gzip", <-encodings)
+	assert.Equal(t, "us", events[0].Tags["region"])
+}
+
+// panicError is an error which panics when formatted.
+type panicError struct{}
+
+func (panicError) Error() string { panic("broken error") }
+
+func TestCaptureErrorsPanicRecovered(t *testing.T) {
+	server := raventest.NewServer()
+	defer server.Close()
+
+	for name, capture := range map[string]func(<-chan glog.Event){
+		"CaptureErrors":       func(comm <-chan glog.Event) { raven.CaptureErrors("example", server.DSN(), comm) },
+		"CaptureErrorsAltDsn": func(comm <-chan glog.Event) { raven.CaptureErrorsAltDsn("example", []string{server.DSN()}, comm) },
+	} {
+		comm := make(chan glog.Event, 2)
+		comm <- glog.Event{Severity: "ERROR", Message: []byte("broken"), Data: []interface{}{glog.ErrorArg{Error: panicError{}}}}
+		comm <- glog.Event{Severity: "ERROR", Message: []byte(name)}
+		close(comm)
+		capture(comm)
+
+		events := server.Events()
+		require.NotEmpty(t, events, name)
+		assert.Equal(t, name, events[len(events)-1].Message, "capture continues after a panic")
+	}
+}
+
+func TestFingerprintMatchesSentry(t *testing.T) {
+	flag.Set("sentryFingerprinting", "true")
+	defer flag.Set("sentryFingerprinting", "false")
+
+	server := raventest.NewServer()
+	defer server.Close()
+
+	client, err := raven.NewClient(server.DSN())
+	require.NoError(t, err)
+
+	callers := make([]uintptr, 20)
+	written := runtime.Callers(1, callers)
+	e := glog.Event{Severity: "ERROR", Message: []byte("test message"), StackTrace: callers[:written]}
+
+	client.CaptureGlogEvent(e)
+	events := server.Events()
+	require.Len(t, events, 1)
+
+	expected, _ := sentry.FromGlogEvent(e)
+	require.NotEmpty(t, expected.Fingerprint)
+	assert.Equal(t, expected.Fingerprint, events[0].Fingerprint)
+}
+
+func TestCaptureErrorsWithContext(t *testing.T) {
+	server := raventest.NewServer()
+	defer server.Close()
+
+	comm := make(chan glog.Event, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- raven.CaptureErrorsWithContext(ctx, "example", []string{server.DSN()}, comm)
+	}()
+
+	comm <- glog.Event{Severity: "ERROR", Message: []byte("test message")}
+	cancel()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("capturing did not stop")
+	}
+	events := server.Events()
+	require.Len(t, events, 1, "events waiting in the channel are captured")
+	assert.Equal(t, "test message", events[0].Message)
+
+	assert.Error(t, raven.CaptureErrorsWithContext(context.Background(), "example", nil, comm))
+}
+
+func TestCaptureEncoding(t *testing.T) {
+	type request struct {
+		header http.Header
+		body   []byte
+	}
+	requests := make(chan request, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		requests <- request{r.Header, body}
+	}))
+	defer server.Close()
+	dsn := strings.Replace(server.URL, "://", "://public:secret@", 1) + "/1"
+
+	client, err := raven.NewClient(dsn)
+	require.NoError(t, err)
+	require.NoError(t, client.Capture(&raven.Event{Message: "legacy"}))
+	r := <-requests
+	assert.Equal(t, "application/octet-stream", r.header.Get("Content-Type"))
+	assert.Empty(t, r.header.Get("Content-Encoding"))
+	body, err := zlib.NewReader(base64.NewDecoder(base64.StdEncoding, bytes.NewReader(r.body)))
+	require.NoError(t, err, "body is base64 encoded and zlib compressed by default")
+	var ev raven.Event
+	require.NoError(t, json.NewDecoder(body).Decode(&ev))
+	assert.Equal(t, "legacy", ev.Message)
+
+	client.SetGzip(true)
+	require.NoError(t, client.Capture(&raven.Event{Message: "gzip"}))
+	r = <-requests
+	assert.Equal(t, "application/json", r.header.Get("Content-Type"))
+	assert.Equal(t, "gzip", r.header.Get("Content-Encoding"))
+	body, err = gzip.NewReader(bytes.NewReader(r.body))
+	require.NoError(t, err, "body is gzip compressed")
+	require.NoError(t, json.NewDecoder(body).Decode(&ev))
+	assert.Equal(t, "gzip", ev.Message)
+
+	raventestServer := raventest.NewServer()
+	defer raventestServer.Close()
+	client, err = raven.NewClient(raventestServer.DSN())
+	require.NoError(t, err)
+	client.SetGzip(true)
+	require.NoError(t, client.Capture(&raven.Event{Message: "gzip"}))
+	require.Len(t, raventestServer.Events(), 1, "the test server accepts gzip events")
+}
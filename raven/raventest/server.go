@@ -0,0 +1,102 @@
+// The raventest package provides a mock Sentry server for testing code which
+// sends events using the raven package, without requiring network access or
+// a real Sentry instance.
+//
+//	server := raventest.NewServer()
+//	defer server.Close()
+//	client, _ := raven.NewClient(server.DSN())
+//	client.CaptureMessage("test")
+//	events := server.Events()
+package raventest
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	"github.com/yext/glog-contrib/raven"
+)
+
+// The credentials and project expected by the server.
+const (
+	PublicKey = "public"
+	SecretKey = "secret"
+	Project   = "1"
+)
+
+// Server is a mock Sentry server which accepts events sent to the legacy
+// store endpoint, as raven.Client does.
+type Server struct {
+	*httptest.Server
+
+	mu     sync.Mutex
+	events []raven.Event
+}
+
+// NewServer starts and returns a new Server. The caller should call
+// Close when finished, to shut it down.
+func NewServer() *Server {
+	s := &Server{}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// DSN returns a DSN which can be used to send events to the server.
+func (s *Server) DSN() string {
+	return strings.Replace(s.URL, "://", fmt.Sprintf("://%s:%s@", PublicKey, SecretKey), 1) + "/" + Project
+}
+
+// Events returns the events which have been received by the server.
+func (s *Server) Events() []raven.Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]raven.Event(nil), s.events...)
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.URL.Path != "/api/"+Project+"/store/" {
+		http.Error(w, "unexpected request: "+r.Method+" "+r.URL.Path, http.StatusNotFound)
+		return
+	}
+	if !strings.Contains(r.Header.Get("X-Sentry-Auth"), "sentry_key="+PublicKey) {
+		http.Error(w, "invalid auth header: "+r.Header.Get("X-Sentry-Auth"), http.StatusUnauthorized)
+		return
+	}
+
+	ev, err := decodeEvent(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	s.events = append(s.events, ev)
+	s.mu.Unlock()
+}
+
+// decodeEvent decodes the base64 encoded, zlib compressed JSON event
+// in the body of the request, or the gzip compressed JSON event if the
+// request has a gzip Content-Encoding.
+func decodeEvent(r *http.Request) (raven.Event, error) {
+	var ev raven.Event
+	var body io.ReadCloser
+	var err error
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		body, err = gzip.NewReader(r.Body)
+	} else {
+		body, err = zlib.NewReader(base64.NewDecoder(base64.StdEncoding, r.Body))
+	}
+	if err != nil {
+		return ev, err
+	}
+	defer body.Close()
+	err = json.NewDecoder(body).Decode(&ev)
+	return ev, err
+}
@@ -1,5 +1,11 @@
 package sentry
 
+import (
+	"context"
+
+	"github.com/getsentry/sentry-go"
+)
+
 // Contains attributes which can be passed to glog, which will be used
 // by this package to route and process Sentry errors accordingly.
 
@@ -11,6 +17,16 @@ func AltDsn(dsn string) interface{} {
 	return altDsn(dsn)
 }
 
+type altDsns []string
+
+// AltDsns can be used as a glog attribute to send the issue to each of the
+// given DSNs, such as both a team project and a central one. DSNs which no
+// client was created for are sent to the primary DSN instead (at most once).
+// Several AltDsn attributes on one event are treated the same way.
+func AltDsns(dsns ...string) interface{} {
+	return altDsns(dsns)
+}
+
 type fingerprint []string
 
 // Fingerprint creates a Sentry fingerprint from a variadic set of strings.
@@ -18,4 +34,177 @@ type fingerprint []string
 // See: https://docs.sentry.io/learn/rollups/#customize-grouping-with-fingerprints
 func Fingerprint(print ...string) interface{} {
 	return fingerprint(print)
-}
\ No newline at end of file
+}
+
+type tags map[string]string
+
+// Tags can be used as a glog attribute to tag the issue with the given key/value
+// pairs. The tags of multiple Tags attributes on one event are merged, with
+// later ones taking precedence.
+func Tags(t map[string]string) interface{} {
+	return tags(t)
+}
+
+type user sentry.User
+
+// User can be used as a glog attribute to set the user affected by the issue,
+// so that Sentry can count the users impacted by it. If an *http.Request is
+// also passed, the IP address of the user defaults to the remote address of
+// the request, anonymized as set by SetIPAnonymization.
+func User(u sentry.User) interface{} {
+	return user(u)
+}
+
+type breadcrumbs []sentry.Breadcrumb
+
+// Breadcrumbs can be used as a glog attribute to attach breadcrumbs leading up
+// to the issue. The breadcrumbs of multiple Breadcrumbs attributes on one event
+// are attached in order, keeping only the most recent 100 (the most Sentry
+// accepts). Breadcrumbs without a timestamp are given the time they were
+// converted.
+func Breadcrumbs(crumbs ...sentry.Breadcrumb) interface{} {
+	return breadcrumbs(crumbs)
+}
+
+type serverName string
+
+// ServerName can be used as a glog attribute to override the server name
+// of the issue, for events representing work done on behalf of another host
+// (such as a proxied request). Defaults to the hostname of this process.
+func ServerName(name string) interface{} {
+	return serverName(name)
+}
+
+type environment string
+
+// Environment can be used as a glog attribute to override the environment of
+// the issue, for events about work done in another environment (such as for a
+// tenant in staging). Defaults to the environment of the client.
+func Environment(env string) interface{} {
+	return environment(env)
+}
+
+type eventScope struct {
+	scope *sentry.Scope
+}
+
+// Scope can be used as a glog attribute to capture the issue with the given
+// scope, such as one populated with tags or user data for the current request.
+// The event is captured on a clone of the hub for the target DSN, so the scope
+// is isolated to this event and does not affect any others.
+func Scope(scope *sentry.Scope) interface{} {
+	return eventScope{scope}
+}
+
+type route string
+
+// Route can be used as a glog attribute to specify the route pattern matched
+// by the request being handled (such as "/users/{id}"). It is used for the
+// transaction and "route" tag of the issue, rather than the raw request path
+// which may contain unique identifiers.
+func Route(pattern string) interface{} {
+	return route(pattern)
+}
+
+type transaction string
+
+// Transaction can be used as a glog attribute to set the transaction (the
+// name of the endpoint or operation) of the issue, so that issues can be
+// browsed by transaction in Sentry. It takes precedence over a Route, and
+// defaults to the method and path of the request, if one is passed.
+func Transaction(name string) interface{} {
+	return transaction(name)
+}
+
+type skipFrames int
+
+// SkipFrames can be used as a glog attribute to skip the given number of
+// innermost frames of the stack trace of the glog invocation, for logging
+// wrappers which call glog on behalf of their caller. The issue is then
+// reported (and grouped) at the call site of the wrapper, such as with
+// SkipFrames(1) for a wrapper which calls glog directly. It is ignored if the
+// stack trace doesn't have more frames than that.
+func SkipFrames(n int) interface{} {
+	return skipFrames(n)
+}
+
+type tenant string
+
+// Tenant can be used as a glog attribute to tag the issue with the tenant
+// (as "tenant") on whose behalf the work was done. If a DSN is set for the
+// tenant by SetTenantDsns, the issue is sent to it, unless an AltDsn is also
+// provided.
+func Tenant(id string) interface{} {
+	return tenant(id)
+}
+
+type logger string
+
+// Logger can be used as a glog attribute to override the logger of the issue,
+// allowing issues to be faceted by subsystem in Sentry. Defaults to the path
+// of the running binary.
+func Logger(name string) interface{} {
+	return logger(name)
+}
+
+type thread struct {
+	name string
+	pcs  []uintptr
+}
+
+// Thread can be used as a glog attribute to attach an additional named
+// stacktrace to the issue, given program counters as returned by
+// runtime.Callers. This is useful for errors spanning goroutines, such as
+// a result from a worker reported by a coordinator.
+func Thread(name string, pcs []uintptr) interface{} {
+	return thread{name, pcs}
+}
+
+type fieldErrors map[string]string
+
+// FieldErrors can be used as a glog attribute to attach validation errors,
+// as a map of field names to messages. The errors are attached to the extra
+// data of the issue, and unless a Fingerprint is provided, the issue is
+// grouped by the set of failing fields rather than their specific messages.
+func FieldErrors(errs map[string]string) interface{} {
+	return fieldErrors(errs)
+}
+
+type handled bool
+
+// Handled can be used as a glog attribute to mark whether the error was
+// handled (logged and recovered from) or unhandled (such as a crash). It is
+// reported as the "error_handled" tag ("yes" or "no"), overriding the default
+// of unhandled for panics reported by Recoverer, so that issues can be
+// searched by it. It is only a tag: it doesn't set the handled flag of the
+// exception's mechanism, so Sentry doesn't count it in crash-free metrics.
+func Handled(h bool) interface{} {
+	return handled(h)
+}
+
+type eventContext struct {
+	ctx context.Context
+}
+
+// Context can be used as a glog attribute to capture the issue with the scope
+// carried by the context, as set by WithRequestScope. A Scope attribute takes
+// precedence. The event is also tagged by any extractors registered with
+// RegisterContextExtractor. To apply it to every error logged for a request, the attribute
+// can be stored in the context for glog:
+//
+//	ctx = sentry.WithRequestScope(ctx, scope)
+//	ctx = glog.ContextWithData(ctx, sentry.Context(ctx))
+//	glog.WithContext(ctx).Error("request failed")
+func Context(ctx context.Context) interface{} {
+	return eventContext{ctx}
+}
+
+type force struct{}
+
+// Force can be used as a glog attribute to guarantee that a rare, critical
+// issue is sent to Sentry, bypassing the error filters, sampling, metrics
+// aggregation, deduplication and pacing configured by the Options provided to
+// CaptureErrors.
+func Force() interface{} {
+	return force{}
+}
@@ -0,0 +1,243 @@
+package sentry_test
+
+import (
+	"context"
+	"net/http/httptest"
+	"runtime"
+	"strconv"
+	"testing"
+
+	sentrygo "github.com/getsentry/sentry-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yext/glog"
+	"github.com/yext/glog-contrib/sentry"
+)
+
+func TestTags(t *testing.T) {
+	e, _ := sentry.FromGlogEvent(newErrorEvent("test message",
+		sentry.Tags(map[string]string{"shard": "us-east-3", "tier": "1"}),
+		sentry.Tags(map[string]string{"tier": "2", "replica": "b"})))
+	assert.Equal(t, "us-east-3", e.Tags["shard"])
+	assert.Equal(t, "b", e.Tags["replica"], "tags are merged")
+	assert.Equal(t, "2", e.Tags["tier"], "later tags take precedence")
+}
+
+func TestUser(t *testing.T) {
+	req := httptest.NewRequest("GET", "/path", nil)
+	req.RemoteAddr = "203.0.113.7:54321"
+
+	e, _ := sentry.FromGlogEvent(newErrorEvent("test message", sentry.User(sentrygo.User{ID: "42"})))
+	assert.Equal(t, sentrygo.User{ID: "42"}, e.User)
+
+	e, _ = sentry.FromGlogEvent(newErrorEvent("test message", req, sentry.User(sentrygo.User{ID: "42"})))
+	assert.Equal(t, sentrygo.User{ID: "42", IPAddress: "203.0.113.0"}, e.User, "IP address defaults to the masked remote address")
+
+	e, _ = sentry.FromGlogEvent(newErrorEvent("test message", req, sentry.User(sentrygo.User{ID: "42", IPAddress: "198.51.100.1"})))
+	assert.Equal(t, "198.51.100.1", e.User.IPAddress, "explicit IP address takes precedence")
+
+	e, _ = sentry.FromGlogEvent(newErrorEvent("test message", req))
+	assert.Empty(t, e.User.IPAddress, "no user is set without the attribute")
+}
+
+func TestBreadcrumbs(t *testing.T) {
+	e, _ := sentry.FromGlogEvent(newErrorEvent("test message",
+		sentry.Breadcrumbs(sentrygo.Breadcrumb{Message: "first"}, sentrygo.Breadcrumb{Message: "second"}),
+		sentry.Breadcrumbs(sentrygo.Breadcrumb{Message: "third"})))
+	require.Len(t, e.Breadcrumbs, 3)
+	assert.Equal(t, "first", e.Breadcrumbs[0].Message, "order is preserved")
+	assert.Equal(t, "second", e.Breadcrumbs[1].Message)
+	assert.Equal(t, "third", e.Breadcrumbs[2].Message)
+	assert.False(t, e.Breadcrumbs[0].Timestamp.IsZero(), "timestamp is set")
+
+	var crumbs []sentrygo.Breadcrumb
+	for i := 0; i < 120; i++ {
+		crumbs = append(crumbs, sentrygo.Breadcrumb{Message: strconv.Itoa(i)})
+	}
+	e, _ = sentry.FromGlogEvent(newErrorEvent("test message", sentry.Breadcrumbs(crumbs...)))
+	require.Len(t, e.Breadcrumbs, 100, "breadcrumbs are capped")
+	assert.Equal(t, "20", e.Breadcrumbs[0].Message, "the most recent are kept")
+	assert.Equal(t, "119", e.Breadcrumbs[99].Message)
+}
+
+func TestServerName(t *testing.T) {
+	e, _ := sentry.FromGlogEvent(newErrorEvent("test message"))
+	assert.NotEmpty(t, e.ServerName, "defaults to the hostname")
+	assert.NotEqual(t, "origin-host", e.ServerName)
+
+	e, _ = sentry.FromGlogEvent(newErrorEvent("test message", sentry.ServerName("origin-host")))
+	assert.Equal(t, "origin-host", e.ServerName, "server name is overridden")
+}
+
+func TestEnvironmentAttribute(t *testing.T) {
+	t.Setenv("SENTRY_ENVIRONMENT", "")
+	events := captureEvents([]glog.Event{
+		newErrorEvent("first message", sentry.Environment("staging")),
+		newErrorEvent("second message"),
+	})
+	require.Len(t, events, 2)
+	assert.Equal(t, "staging", events[0].Environment, "environment is overridden")
+	assert.Empty(t, events[1].Environment, "other events have none")
+}
+
+func TestScope(t *testing.T) {
+	scope := sentrygo.NewScope()
+	scope.SetTag("request", "1")
+	events := captureEvents([]glog.Event{
+		newErrorEvent("first message", sentry.Scope(scope)),
+		newErrorEvent("second message"),
+	})
+
+	assert.Len(t, events, 2)
+	assert.Equal(t, "1", events[0].Tags["request"], "scope is applied to the event")
+	assert.NotContains(t, events[1].Tags, "request", "scope does not leak to the next event")
+}
+
+func TestContext(t *testing.T) {
+	requestScope := sentrygo.NewScope()
+	requestScope.SetTag("request", "1")
+	ctx := sentry.WithRequestScope(context.Background(), requestScope)
+	assert.Same(t, requestScope, sentry.ScopeFromContext(ctx))
+
+	explicit := sentrygo.NewScope()
+	explicit.SetTag("request", "2")
+	events := captureEvents([]glog.Event{
+		newErrorEvent("first message", sentry.Context(ctx)),
+		newErrorEvent("second message", sentry.Context(ctx)),
+		newErrorEvent("third message", sentry.Context(ctx), sentry.Scope(explicit)),
+		newErrorEvent("fourth message", sentry.Context(context.Background())),
+	})
+
+	assert.Len(t, events, 4)
+	assert.Equal(t, "1", events[0].Tags["request"], "scope from the context is applied")
+	assert.Equal(t, "1", events[1].Tags["request"], "scope applies to each event with the context")
+	assert.Equal(t, "2", events[2].Tags["request"], "scope attribute takes precedence")
+	assert.NotContains(t, events[3].Tags, "request")
+}
+
+func TestRoute(t *testing.T) {
+	req := httptest.NewRequest("GET", "/users/12345/orders/67890", nil)
+
+	e, _ := sentry.FromGlogEvent(newErrorEvent("test message", req))
+	assert.Equal(t, "GET /users/12345/orders/67890", e.Transaction, "falls back to the method and raw path")
+	assert.NotContains(t, e.Tags, "route")
+
+	e, _ = sentry.FromGlogEvent(newErrorEvent("test message", req, sentry.Route("/users/{id}/orders/{id}")))
+	assert.Equal(t, "/users/{id}/orders/{id}", e.Transaction, "route is used for the transaction")
+	assert.Equal(t, "/users/{id}/orders/{id}", e.Tags["route"], "route is tagged")
+}
+
+func TestTransaction(t *testing.T) {
+	e, _ := sentry.FromGlogEvent(newErrorEvent("test message"))
+	assert.Empty(t, e.Transaction, "no transaction without a request")
+
+	e, _ = sentry.FromGlogEvent(newErrorEvent("test message", sentry.Transaction("nightly-import")))
+	assert.Equal(t, "nightly-import", e.Transaction)
+
+	req := httptest.NewRequest("POST", "/users/12345", nil)
+	e, _ = sentry.FromGlogEvent(newErrorEvent("test message", sentry.Transaction("UpdateUser"), req, sentry.Route("/users/{id}")))
+	assert.Equal(t, "UpdateUser", e.Transaction, "transaction takes precedence over the request and route")
+}
+
+func TestLogger(t *testing.T) {
+	e, _ := sentry.FromGlogEvent(newErrorEvent("test message"))
+	assert.NotEmpty(t, e.Logger, "defaults to the binary path")
+	assert.NotEqual(t, "billing", e.Logger)
+
+	e, _ = sentry.FromGlogEvent(newErrorEvent("test message", sentry.Logger("billing")))
+	assert.Equal(t, "billing", e.Logger, "logger is overridden")
+}
+
+func TestThread(t *testing.T) {
+	pcs := make(chan []uintptr)
+	go func() {
+		callers := make([]uintptr, 20)
+		written := runtime.Callers(1, callers)
+		pcs <- callers[:written]
+	}()
+	worker := <-pcs
+
+	e, _ := sentry.FromGlogEvent(newErrorEvent("test message", sentry.Thread("worker", worker)))
+	assert.Len(t, e.Threads, 1)
+	assert.Equal(t, "worker", e.Threads[0].Name)
+	assert.NotNil(t, e.Threads[0].Stacktrace)
+	frames := e.Threads[0].Stacktrace.Frames
+	assert.NotEmpty(t, frames)
+	assert.Equal(t, "TestThread.func1", frames[len(frames)-1].Function, "innermost frame is in the worker goroutine")
+}
+
+func TestFieldErrors(t *testing.T) {
+	a, _ := sentry.FromGlogEvent(newErrorEvent("validation failed", sentry.FieldErrors(map[string]string{
+		"name":  "must not be empty",
+		"email": "invalid address: a@",
+	})))
+	b, _ := sentry.FromGlogEvent(newErrorEvent("validation failed", sentry.FieldErrors(map[string]string{
+		"email": "invalid address: b@",
+		"name":  "too long",
+	})))
+	c, _ := sentry.FromGlogEvent(newErrorEvent("validation failed", sentry.FieldErrors(map[string]string{
+		"email": "invalid address: c@",
+	})))
+
+	assert.Equal(t, map[string]string{"name": "must not be empty", "email": "invalid address: a@"}, a.Extra["FieldErrors"])
+	assert.Equal(t, []string{"field errors", "email", "name"}, a.Fingerprint)
+	assert.Equal(t, a.Fingerprint, b.Fingerprint, "same set of failing fields are grouped")
+	assert.NotEqual(t, a.Fingerprint, c.Fingerprint, "different set of failing fields are not grouped")
+
+	e, _ := sentry.FromGlogEvent(newErrorEvent("validation failed",
+		sentry.FieldErrors(map[string]string{"name": "must not be empty"}), sentry.Fingerprint("custom")))
+	assert.Equal(t, []string{"custom"}, e.Fingerprint, "explicit fingerprint takes precedence")
+}
+
+func TestHandled(t *testing.T) {
+	e, _ := sentry.FromGlogEvent(newErrorEvent("test message"))
+	assert.NotContains(t, e.Tags, "error_handled", "not set by default")
+
+	e, _ = sentry.FromGlogEvent(newErrorEvent("test message", sentry.Handled(true)))
+	assert.Equal(t, "yes", e.Tags["error_handled"])
+
+	e, _ = sentry.FromGlogEvent(newErrorEvent("test message", sentry.Handled(false)))
+	assert.Equal(t, "no", e.Tags["error_handled"])
+}
+
+// logError is a logging wrapper, which calls glog on behalf of its caller.
+func logError(msg string, data ...interface{}) glog.Event {
+	return newErrorEvent(msg, data...)
+}
+
+func TestSkipFrames(t *testing.T) {
+	callSite := func(e *sentrygo.Event) string {
+		frames := e.Exception[0].Stacktrace.Frames
+		return frames[len(frames)-1].Function
+	}
+
+	e, _ := sentry.FromGlogEvent(logError("test message"))
+	assert.Equal(t, "logError", callSite(e), "the wrapper is the call site by default")
+
+	e, _ = sentry.FromGlogEvent(logError("test message", sentry.SkipFrames(1)))
+	assert.Equal(t, "TestSkipFrames", callSite(e), "the caller of the wrapper is the call site")
+
+	e, _ = sentry.FromGlogEvent(logError("test message", sentry.SkipFrames(100)))
+	assert.Equal(t, "logError", callSite(e), "skipping every frame is ignored")
+}
+
+func TestTenant(t *testing.T) {
+	const tenantDsn = "https://public@sentry.example.com/2"
+	sentry.SetTenantDsns(map[string]string{"large": tenantDsn})
+	defer sentry.SetTenantDsns(nil)
+
+	e, dsn := sentry.FromGlogEvent(newErrorEvent("test message", sentry.Tenant("large")))
+	assert.Equal(t, "large", e.Tags["tenant"])
+	assert.Equal(t, tenantDsn, dsn, "routed to the DSN of the tenant")
+
+	e, dsn = sentry.FromGlogEvent(newErrorEvent("test message", sentry.Tenant("small")))
+	assert.Equal(t, "small", e.Tags["tenant"])
+	assert.Empty(t, dsn, "tenants without a DSN are sent to the primary DSN")
+
+	_, dsn = sentry.FromGlogEvent(newErrorEvent("test message", sentry.Tenant("large"), sentry.AltDsn("https://public@sentry.example.com/3")))
+	assert.Equal(t, "https://public@sentry.example.com/3", dsn, "AltDsn takes precedence")
+
+	events := captureEvents([]glog.Event{newErrorEvent("test message", sentry.Tenant("large"))})
+	require.Len(t, events, 1, "a client is created for the DSN of the tenant")
+	assert.Equal(t, "large", events[0].Tags["tenant"])
+}
@@ -8,26 +8,129 @@
 package sentry
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"log"
+	"net"
 	"net/http"
 	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/getsentry/sentry-go"
 	"github.com/yext/glog"
+	"github.com/yext/glog-contrib/internal/convert"
 	"github.com/yext/glog-contrib/stacktrace"
 )
 
-// The maximum number of wrapped errors processed.
-const maxErrorDepth = 10
+// The default maximum depth of wrapped errors processed.
+const defaultMaxErrorDepth = 10
+
+var (
+	maxErrorDepthMu sync.RWMutex
+	maxErrorDepth   = defaultMaxErrorDepth
+)
+
+// SetMaxErrorDepth sets the maximum depth of the errors unwrapped from each
+// error passed to glog, including the error itself, which defaults to 10.
+// Errors wrapped any deeper are not converted to exceptions.
+func SetMaxErrorDepth(depth int) {
+	maxErrorDepthMu.Lock()
+	defer maxErrorDepthMu.Unlock()
+	maxErrorDepth = depth
+}
+
+// getMaxErrorDepth returns the maximum depth of wrapped errors processed.
+func getMaxErrorDepth() int {
+	maxErrorDepthMu.RLock()
+	defer maxErrorDepthMu.RUnlock()
+	return maxErrorDepth
+}
+
+var (
+	maxDataDepthMu sync.RWMutex
+	maxDataDepth   int
+)
+
+// SetMaxDataDepth sets the maximum depth of the data passed to glog (as a
+// map[string]interface{}) which is attached to events, where the values of
+// the map are at a depth of one. Maps and slices whose contents would be
+// nested any deeper are replaced with a "[truncated: depth]" marker, since
+// Sentry displays them poorly and they bloat the event. Zero (the default)
+// means no limit.
+func SetMaxDataDepth(depth int) {
+	maxDataDepthMu.Lock()
+	defer maxDataDepthMu.Unlock()
+	maxDataDepth = depth
+}
+
+func getMaxDataDepth() int {
+	maxDataDepthMu.RLock()
+	defer maxDataDepthMu.RUnlock()
+	return maxDataDepth
+}
+
+// The marker which replaces data nested beyond the maximum depth.
+const truncatedDepth = "[truncated: depth]"
+
+// truncateDepth returns the value at the given depth of the data, with any
+// maps and slices nested beyond the maximum depth replaced by a marker.
+func truncateDepth(v interface{}, depth, maxDepth int) interface{} {
+	if v == nil {
+		return nil
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Map:
+		if depth >= maxDepth {
+			return truncatedDepth
+		}
+		m := make(map[string]interface{}, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			m[fmt.Sprint(iter.Key().Interface())] = truncateDepth(iter.Value().Interface(), depth+1, maxDepth)
+		}
+		return m
+	case reflect.Slice, reflect.Array:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return v
+		}
+		if depth >= maxDepth {
+			return truncatedDepth
+		}
+		s := make([]interface{}, rv.Len())
+		for i := range s {
+			s[i] = truncateDepth(rv.Index(i).Interface(), depth+1, maxDepth)
+		}
+		return s
+	}
+	return v
+}
 
 var (
 	sentryDebug = flag.Bool("sentryDebug", false,
 		"enable debug mode in Sentry clients")
 	sentryFingerprinting = flag.Bool("sentryFingerprinting", false,
 		"enable server-side issue fingerprinting. If set, duplicate issues will only be tracked if they have equivalent filenames and line numbers")
+	sentryRawExceptionOrder = flag.Bool("sentryRawExceptionOrder", false,
+		"keep exceptions in the order they were unwrapped (outermost error first, followed by the glog invocation) rather than reversing them")
+	sentryErrorChain = flag.Bool("sentryErrorChain", false,
+		"attach the full chain of errors unwrapped from each event (with their types, messages and how they were unwrapped) to the event as a gzipped JSON attachment, for debugging")
+	sentryConversionDeadline = flag.Duration("sentryConversionDeadline", 0,
+		"if set, the maximum time spent converting the errors and data of each event. Conversion stops at the deadline and the event is tagged with conversion_truncated")
+	sentryMaxExceptions = flag.Int("sentryMaxExceptions", 0,
+		"if set, the maximum number of exceptions in each event (including the glog invocation). The innermost causes are kept, and the remainder are summarized in the extra data")
+	sentryMessageFingerprinting = flag.Bool("sentryMessageFingerprinting", false,
+		"group events without any in-app stack frames by their message, normalized by the title transformers (or by stripping UUIDs, collapsing digits and normalizing whitespace, if none are set)")
+	sentryEnvironmentFromHostname = flag.String("sentryEnvironmentFromHostname", "",
+		"optional regular expression used to derive the Sentry environment from the hostname. The first capture group (or the entire match, if there are no groups) is used as the environment")
 
 	hostname string
 )
@@ -53,86 +156,307 @@ func init() {
 //		},
 //		glog.RegisterBackend())
 //
-// When an event is received via glog at the ERROR severity,
+// When an event is received via glog at the ERROR (or FATAL) severity,
 // the first provided DSN will be used, unless a sentry.AltDsn is
 // tagged on the glog event, in which case the specified client
 // for that DSN will be used:
 //
 //	glog.Error("error for secondary DSN", sentry.AltDsn("https://optionalSecondaryDsn"))
-func CaptureErrors(project string, dsns []string, opts sentry.ClientOptions, comm <-chan glog.Event) {
-	// If no DSNs specified, panic (we can't invoke glog)
-	if len(dsns) == 0 {
-		panic("must specify at least one Sentry DSN")
+//
+// An event may be sent to several DSNs with sentry.AltDsns.
+//
+// The behavior of the capture loop can be further configured
+// by passing any number of Options.
+func CaptureErrors(project string, dsns []string, opts sentry.ClientOptions, comm <-chan glog.Event, options ...Option) {
+	// If unable to start capturing errors, panic (we can't invoke glog)
+	if err := CaptureErrorsE(project, dsns, opts, comm, options...); err != nil {
+		panic(err)
 	}
+}
 
-	hubs := make(map[string]*sentry.Hub)
-	var primaryHub *sentry.Hub
-	for _, dsn := range dsns {
-		client, err := sentry.NewClient(buildClientOptions(dsn, opts))
+// CaptureErrorsE is like CaptureErrors, but returns an error rather than
+// panicking if the Sentry clients cannot be initialized. It returns nil once
+// the glog channel closes and any pending events have been flushed, so that
+// supervising code can decide whether to restart it.
+func CaptureErrorsE(project string, dsns []string, opts sentry.ClientOptions, comm <-chan glog.Event, options ...Option) error {
+	return CaptureErrorsWithContext(context.Background(), project, dsns, opts, comm, options...)
+}
+
+// CaptureErrorsWithContext is like CaptureErrorsE, but also stops capturing
+// errors when the context is done, such as when the application receives
+// SIGTERM. Any events already waiting in the glog channel are captured, and
+// pending events are flushed (waiting up to the timeout set by
+// WithFlushTimeout) before it returns. The transports of the clients are
+// then stopped and their connections closed, so capturing may be restarted
+// (such as with other DSNs) without leaking them.
+func CaptureErrorsWithContext(ctx context.Context, project string, dsns []string, opts sentry.ClientOptions, comm <-chan glog.Event, options ...Option) error {
+	if len(dsns) == 0 {
+		return errors.New("must specify at least one Sentry DSN")
+	}
 
-		// If unable to initialize the Sentry client, panic (we can't invoke glog)
+	c, err := newCapturer(options)
+	if err != nil {
+		return err
+	}
+	userTransport := opts.HTTPTransport
+	opts = c.clientOptions(opts)
+	// This transport is equivalent to the one the clients would otherwise
+	// create, but can be closed.
+	if opts.HTTPTransport == nil && opts.HTTPClient == nil {
+		opts.HTTPTransport = httpTransport(opts)
+	}
+	// Close the connections of the clients once capturing stops, so that they
+	// aren't leaked if it is restarted.
+	if t, ok := opts.HTTPTransport.(*http.Transport); ok && opts.HTTPTransport != userTransport {
+		defer t.CloseIdleConnections()
+	}
+	primaryOpts, err := buildClientOptions(dsns[0], opts)
+	if err != nil {
+		return err
+	}
+	setPrimaryOptions(primaryOpts)
+	// The transport used to send the events of the retry queue in the
+	// background. Each entry has its own DSN, so any of them will do.
+	var retrying *retryTransport
+	for _, dsn := range withTenantDsns(dsns) {
+		clientOpts, err := buildClientOptions(dsn, opts)
 		if err != nil {
-			panic(err)
+			return err
+		}
+		if clientOpts.Transport == nil && dsn != "" {
+			if c.retry != nil {
+				transport := c.retry.transport(c.counters)
+				if retrying == nil {
+					retrying = transport
+				}
+				clientOpts.Transport = transport
+			} else {
+				// Unlike the transport the client would create, this one
+				// stops sending once capturing stops, after the client is
+				// flushed.
+				transport := newAsyncTransport(clientOpts)
+				defer transport.close()
+				clientOpts.Transport = transport
+			}
+		}
+		client, err := sentry.NewClient(clientOpts)
+		if err != nil {
+			return err
 		}
 
 		// Initialize a Hub (which contains additional scope)
 		scope := sentry.NewScope()
 		hub := sentry.NewHub(client, scope)
 
-		// Set the first provided DSN as the primary hub
-		if primaryHub == nil {
-			primaryHub = hub
-		}
-
 		// Configure the cleanup period for the newly initialized client
-		defer client.Flush(1 * time.Second)
+		defer client.Flush(c.flushTimeout)
 
-		hubs[dsn] = hub
+		// The first provided DSN is the primary hub
+		c.addHub(dsn, hub)
+
+		if c.startup {
+			hub.CaptureEvent(startupEvent(project))
+		}
+	}
+	defer c.close()
+	if retrying != nil {
+		c.retry.start(retrying.send)
+		defer c.retry.stop()
 	}
 
-	// This for loop runs indefinitely unless the glog channel closes
-	// (which should only happen on app exit)
-	for glogEvent := range comm {
-		if glogEvent.Severity == "ERROR" {
-			e, targetDsn := FromGlogEvent(glogEvent)
-			if hub, ok := hubs[targetDsn]; ok {
-				hub.CaptureEvent(e)
-			} else {
-				primaryHub.CaptureEvent(e)
+	// This loop runs indefinitely unless the glog channel closes
+	// (which should only happen on app exit) or the context is done
+	for {
+		select {
+		case glogEvent, ok := <-comm:
+			if !ok {
+				// This can't be logged with glog, which may be what closed the channel.
+				fmt.Fprintln(os.Stderr, "sentry: glog channel closed, no longer capturing errors")
+				return nil
+			}
+			if c.captures(glogEvent.Severity) {
+				c.enqueue(glogEvent)
+			}
+		case <-ctx.Done():
+			// Capture the events logged immediately before shutting down.
+			for {
+				select {
+				case glogEvent, ok := <-comm:
+					if !ok {
+						return nil
+					}
+					if c.captures(glogEvent.Severity) {
+						c.enqueue(glogEvent)
+					}
+				default:
+					return nil
+				}
 			}
 		}
 	}
 }
 
-// Adds the dsn, server hostname, and debug status to the provided client options
-func buildClientOptions(dsn string, opts sentry.ClientOptions) sentry.ClientOptions {
+// scopeFromData returns the scope provided via the Scope attribute, or
+// otherwise carried by the context of a Context attribute, if any.
+func scopeFromData(data []interface{}) *sentry.Scope {
+	var fromContext *sentry.Scope
+	for _, d := range data {
+		switch t := d.(type) {
+		case eventScope:
+			if t.scope != nil {
+				return t.scope
+			}
+		case eventContext:
+			if fromContext == nil && t.ctx != nil {
+				fromContext = ScopeFromContext(t.ctx)
+			}
+		}
+	}
+	return fromContext
+}
+
+// Adds the dsn, server hostname, and debug status to the provided client options,
+// as well as the environment resolved by resolveEnvironment.
+func buildClientOptions(dsn string, opts sentry.ClientOptions) (sentry.ClientOptions, error) {
 	opts.Dsn = dsn
 	if !opts.Debug {
 		opts.Debug = *sentryDebug
 	}
 	opts.ServerName = hostname
+	env, err := resolveEnvironment(opts)
+	if err != nil {
+		return opts, err
+	}
+	opts.Environment = env
+
+	return opts, nil
+}
+
+// resolveEnvironment returns the environment of the events captured by a
+// client, which is the first to be set of:
+//
+//  1. the SENTRY_ENVIRONMENT environment variable,
+//  2. the environment derived from the hostname by -sentryEnvironmentFromHostname,
+//  3. the Environment of the client options.
+//
+// It is empty if none of them are set. An environment set on an event takes
+// precedence over all of them. It returns an error if
+// -sentryEnvironmentFromHostname isn't a valid regular expression.
+func resolveEnvironment(opts sentry.ClientOptions) (string, error) {
+	if env := os.Getenv("SENTRY_ENVIRONMENT"); env != "" {
+		return env, nil
+	}
+	if *sentryEnvironmentFromHostname != "" {
+		re, err := regexp.Compile(*sentryEnvironmentFromHostname)
+		if err != nil {
+			return "", fmt.Errorf("invalid -sentryEnvironmentFromHostname: %w", err)
+		}
+		if env := environmentFromHostname(re, hostname); env != "" {
+			return env, nil
+		}
+	}
+	return opts.Environment, nil
+}
 
-	return opts
+// environmentFromHostname returns the environment encoded in the hostname,
+// or an empty string if the hostname does not match the pattern.
+func environmentFromHostname(re *regexp.Regexp, host string) string {
+	m := re.FindStringSubmatch(host)
+	switch {
+	case m == nil:
+		return ""
+	case len(m) > 1:
+		return m[1]
+	default:
+		return m[0]
+	}
 }
 
 // Builds a fingerprint of the filename, function, and line number for all
-// of the frames in the top (most important) exception stacktrace.
+// of the frames in the top (most important) exception stacktrace. Repeated
+// frames (such as from recursion) are only included once, and the entries
+// are sorted so that the fingerprint does not depend on the order of frames.
+// It is empty if there are no exceptions, or the top exception has no stack
+// trace (such as a raw error) or in-app frames.
 func buildFingerprint(exceptions []sentry.Exception) []string {
-	var r []string
-	ex := exceptions[0]
-	for _, f := range ex.Stacktrace.Frames {
-		if f.InApp {
-			r = append(r, fmt.Sprintf("%s in %s at line %d", f.Filename, f.Function, f.Lineno))
+	if len(exceptions) == 0 {
+		return nil
+	}
+	return stacktrace.Fingerprint(exceptions[0].Stacktrace)
+}
+
+// Builds a fingerprint from the first line of the message, normalized by
+// the title transformers.
+func buildMessageFingerprint(message string) []string {
+	firstLine := strings.Split(strings.TrimSpace(message), "\n")[0]
+	return []string{"message", normalizeTitle(firstLine)}
+}
+
+// EventFingerprint returns the fingerprint which the glog event would be
+// grouped by if it were captured: the fingerprint set on the converted event
+// (such as by a Fingerprint attribute), or otherwise one built from the
+// in-app frames of its top exception (or its message, if there are none), as
+// with -sentryFingerprinting. It doesn't require a Sentry client, so it may be
+// used to test that errors are grouped together.
+func EventFingerprint(e glog.Event) []string {
+	s, _ := FromGlogEvent(e)
+	if len(s.Fingerprint) > 0 || len(s.Exception) == 0 {
+		return s.Fingerprint
+	}
+	if fp := buildFingerprint(s.Exception); len(fp) > 0 {
+		return fp
+	}
+	return buildMessageFingerprint(s.Message)
+}
+
+// hasInAppFrames returns whether any of the exceptions have an in-app frame.
+func hasInAppFrames(exceptions []sentry.Exception) bool {
+	for _, ex := range exceptions {
+		if ex.Stacktrace == nil {
+			continue
+		}
+		for _, f := range ex.Stacktrace.Frames {
+			if f.InApp {
+				return true
+			}
 		}
 	}
+	return false
+}
+
+// Builds a fingerprint from the sorted names of the fields which failed validation.
+func buildFieldErrorsFingerprint(errs map[string]string) []string {
+	r := []string{"field errors"}
+	for field := range errs {
+		r = append(r, field)
+	}
+	sort.Strings(r[1:])
 	return r
 }
 
 // FromGlogEvent processes a glog event and generates a corresponding Sentry event.
 // This includes building the stacktrace, cleaning up the error title and subtitle,
 // and identifying whether any TargetDSN or Fingerprint overrides were set.
+// If the event is sent to several DSNs, the first of them is returned.
+//
+// The data passed to glog is handled by its type rather than in argument
+// order. Only the last *http.Request is used, and the tags from its JWT
+// claims are set first, so that tags set by other data (such as Tags)
+// override them. The fields of each map are merged, with later maps
+// overriding earlier ones. Errors are converted last, so that tags extracted
+// from them don't override tags set explicitly.
 func FromGlogEvent(e glog.Event) (*sentry.Event, string) {
-	targetDsn := ""
+	s, targetDsns := fromGlogEvent(e)
+	if len(targetDsns) == 0 {
+		return s, ""
+	}
+	return s, targetDsns[0]
+}
+
+// fromGlogEvent is like FromGlogEvent, but returns all of the DSNs which the
+// event should be sent to, in the order they were provided.
+func fromGlogEvent(e glog.Event) (*sentry.Event, []string) {
+	var targetDsns []string
 
 	s := sentry.NewEvent()
 	// The user may have set a scope in their program which we'd like to respect when
@@ -140,7 +464,7 @@ func FromGlogEvent(e glog.Event) (*sentry.Event, string) {
 	// we use to route to the correct team DSN are disconnected from the hub created within
 	// the sentry package.
 	s = sentry.CurrentHub().Scope().ApplyToEvent(s, nil)
-	s.Message = removeGlogPrefixFromMessage(e.Message)
+	s.Message = removeGlogPrefix(e.Message)
 	s.Level = buildLevel(e.Severity)
 	s.ServerName = hostname
 	s.Logger = stacktrace.GopathRelativeFile(os.Args[0])
@@ -151,68 +475,257 @@ func FromGlogEvent(e glog.Event) (*sentry.Event, string) {
 
 	data := map[string]interface{}{}
 	sanitizedFormatString := ""
-	for _, d := range e.Data {
+	var req *http.Request
+	var validation map[string]string
+	var isHandled *bool
+	var hasUser bool
+	var explicitTransaction string
+	var tenantID string
+	var skip int
+	var errs []error
+
+	// Converting errors and data may be slow in pathological cases, so it
+	// stops at the deadline (if any) and the event is marked as truncated.
+	var deadline time.Time
+	if *sentryConversionDeadline > 0 {
+		deadline = time.Now().Add(*sentryConversionDeadline)
+	}
+	truncated := false
+	expired := func() bool {
+		if !truncated && !deadline.IsZero() && time.Now().After(deadline) {
+			truncated = true
+		}
+		return truncated
+	}
+
+	glogData := convert.ExtractData(e.Data)
+	if glogData.Request != nil {
+		req = glogData.Request
+		s.Request = buildHttpRequest(req)
+		for k, v := range jwtClaimTags(req) {
+			setTag(s, k, v)
+		}
+	}
+
+	for _, d := range glogData.Other {
 		switch t := d.(type) {
 		case altDsn:
-			targetDsn = string(d.(altDsn))
+			targetDsns = appendDsns(targetDsns, string(t))
+		case altDsns:
+			targetDsns = appendDsns(targetDsns, t...)
+		case tenant:
+			setTag(s, "tenant", string(t))
+			tenantID = string(t)
 		case fingerprint:
 			s.Fingerprint = []string(d.(fingerprint))
-		case *http.Request:
-			s.Request = buildHttpRequest(t)
-		case map[string]interface{}:
+		case tags:
 			for k, v := range t {
-				data[k] = v
+				setTag(s, k, v)
 			}
-		case glog.FormatStringArg:
-			// If we have a format string arg, then we can use it
-			// to make a rough approximation of the error's "type"
-			// by removing the format characters (like %s).
-			sanitizedFormatString = cleanupFormatString(t.Format)
-		case glog.ErrorArg:
-			// Prepend the Message with the innermost error message.
-			// This causes it to be used for the headline.
-			hl := headline(t.Error)
-			s.Message = prependMessage(hl, s.Message)
-
-			// Augment the stack trace of the call site with the stack trace in
-			// the error. Loop through and unwrap any chained errors.
-			err := t.Error
-			for i := 0; i < maxErrorDepth && err != nil; i++ {
-				errTrace := stacktrace.ExtractStacktrace(err)
-				fullMsg := prependMessage(headline(err), err.Error())
-
-				// Split the message into parts before and after the colon (:),
-				// if one is present. This removes most unique identifiers from
-				// the type field of the exception.
-				msgType, msgValue := splitMessage(fullMsg)
-				s.Exception = append(s.Exception, sentry.Exception{
-					// Type is the bolded, primary issue title containing the primary component of the error string.
-					// it is utilized in Sentry's event-merge algorithm, so we attempt to remove any potentially
-					// unique components and move them over to the value field.
-					Type: msgType,
-					// Value is the issue subtitle containing any remaining components of the error string,
-					// and the method name/line in which this error was invoked
-					Value:      addExceptionSource(msgValue, errTrace),
-					Stacktrace: errTrace,
-				})
-				switch previous := err.(type) {
-				case interface{ Unwrap() error }:
-					err = previous.Unwrap()
-				case interface{ Cause() error }:
-					err = previous.Cause()
-				default:
-					err = nil
+		case user:
+			s.User = sentry.User(t)
+			hasUser = true
+		case breadcrumbs:
+			for i := range t {
+				b := t[i]
+				if b.Timestamp.IsZero() {
+					b.Timestamp = time.Now()
 				}
+				s.Breadcrumbs = append(s.Breadcrumbs, &b)
+			}
+		case serverName:
+			s.ServerName = string(t)
+		case environment:
+			s.Environment = string(t)
+		case logger:
+			s.Logger = string(t)
+		case mechanism:
+			setTag(s, mechanismTag, string(t))
+			if t == "panic" && isHandled == nil {
+				isHandled = new(bool)
+			}
+		case handled:
+			isHandled = (*bool)(&t)
+		case eventContext:
+			if t.ctx != nil {
+				extractContextTags(t.ctx, s)
 			}
+		case fieldErrors:
+			if validation == nil {
+				validation = map[string]string{}
+			}
+			for k, v := range t {
+				validation[k] = v
+			}
+		case thread:
+			s.Threads = append(s.Threads, sentry.Thread{
+				ID:         strconv.Itoa(len(s.Threads)),
+				Name:       t.name,
+				Stacktrace: stacktrace.ExtractFrames(t.pcs, nil),
+			})
+		case skipFrames:
+			skip = int(t)
+		case transaction:
+			explicitTransaction = string(t)
+		case route:
+			s.Transaction = string(t)
+			setTag(s, "route", string(t))
 		default:
-			// ignored
+			// ignored, unless a registered handler handles it
+			handleData(d, s)
+		}
+	}
+
+	maxDataDepth := getMaxDataDepth()
+	for k, v := range glogData.Fields {
+		if expired() {
+			break
+		}
+		if maxDataDepth > 0 {
+			v = truncateDepth(v, 1, maxDataDepth)
+		}
+		data[k] = v
+	}
+
+	// If we have a format string arg, then we can use it
+	// to make a rough approximation of the error's "type"
+	// by removing the format characters (like %s).
+	if glogData.Format != "" {
+		sanitizedFormatString = cleanupFormatString(glogData.Format)
+	}
+
+	// The position of each error in the chains of all errors logged, by which
+	// its fields are keyed.
+	position := 0
+
+	// The errors are converted last, so that tags extracted from them don't
+	// override tags set explicitly.
+	for _, err := range glogData.Errors {
+		if expired() {
+			break
+		}
+		// Passing a nil error to glog is a mistake, but shouldn't cause
+		// a panic or an empty exception.
+		if isNilError(err) {
+			if *sentryDebug {
+				log.Printf("sentry: skipping nil error passed to glog with message: %s", s.Message)
+			}
+			continue
+		}
+		errs = append(errs, err)
+
+		// Prepend the Message with the innermost error message.
+		// This causes it to be used for the headline.
+		hl := headline(err)
+		s.Message = prependMessage(hl, s.Message)
+
+		// Augment the stack trace of the call site with the stack trace in
+		// the error. Loop through and unwrap any chained or joined errors,
+		// up to maxDepth levels deep.
+		maxDepth := getMaxErrorDepth()
+		chain := []chainedError{{err, 0, ""}}
+		for ; len(chain) > 0 && !expired(); position++ {
+			err, depth := chain[0].err, chain[0].depth
+			chain = chain[1:]
+			errTrace := stacktrace.ExtractStacktrace(err)
+			fullMsg := prependMessage(headline(err), err.Error())
+
+			// Attach any structured fields from the error, keyed
+			// by its position in the chains, so that the fields of
+			// each error logged are kept.
+			if fields := errorFields(err); fields != nil {
+				s.Extra[fmt.Sprintf("ErrorFields.%d", position)] = fields
+			}
+			extractTags(err, s)
+
+			// Split the message into parts before and after the colon (:),
+			// if one is present. This removes most unique identifiers from
+			// the type field of the exception.
+			msgType, msgValue := splitMessage(fullMsg)
+
+			// gRPC errors all share the same "rpc error" prefix, so use the
+			// status code to group them instead and tag the event with it.
+			if code, desc, ok := grpcStatus(err); ok {
+				msgType, msgValue = "rpc error: "+code, desc
+				setTagIfAbsent(s, grpcCodeTag, code)
+			}
+
+			// SQL driver errors are grouped by their error code, rather
+			// than messages which often contain unique values.
+			if code, desc, ok := dbErrorCode(err); ok {
+				msgType, msgValue = "db error: "+code, desc
+				setTagIfAbsent(s, dbErrorCodeTag, code)
+			}
+			s.Exception = append(s.Exception, sentry.Exception{
+				// Type is the bolded, primary issue title containing the primary component of the error string.
+				// it is utilized in Sentry's event-merge algorithm, so we attempt to remove any potentially
+				// unique components and move them over to the value field.
+				Type: msgType,
+				// Value is the issue subtitle containing any remaining components of the error string,
+				// and the method name/line in which this error was invoked
+				Value:      addExceptionSource(msgValue, errTrace),
+				Stacktrace: errTrace,
+			})
+			if depth+1 < maxDepth {
+				chain = append(unwrap(err, depth+1), chain...)
+			}
 		}
 	}
 
+	if truncated {
+		setTag(s, "conversion_truncated", "true")
+	}
+	if isHandled != nil {
+		if *isHandled {
+			setTag(s, handledTag, "yes")
+		} else {
+			setTag(s, handledTag, "no")
+		}
+	}
+
+	// Limit the number of exceptions from errors, keeping the innermost causes
+	// and leaving room for the exception from the glog invocation.
+	if *sentryMaxExceptions > 0 {
+		limitExceptions(s, *sentryMaxExceptions-1)
+	}
+
+	if len(s.Breadcrumbs) > maxBreadcrumbs {
+		s.Breadcrumbs = s.Breadcrumbs[len(s.Breadcrumbs)-maxBreadcrumbs:]
+	}
+	if hasUser && s.User.IPAddress == "" && req != nil {
+		// Hashed addresses aren't valid IP addresses for the user.
+		if ip := remoteIP(req); net.ParseIP(ip) != nil {
+			s.User.IPAddress = ip
+		}
+	}
+
+	// Route the event to the DSN of its tenant, unless another was set
+	if len(targetDsns) == 0 && tenantID != "" {
+		if dsn := tenantDsn(tenantID); dsn != "" {
+			targetDsns = append(targetDsns, dsn)
+		}
+	}
+
+	// Without an explicit transaction or route, fall back to the method and
+	// raw path of the request
+	if explicitTransaction != "" {
+		s.Transaction = explicitTransaction
+	} else if s.Transaction == "" && req != nil {
+		s.Transaction = req.Method + " " + req.URL.Path
+	}
+
 	// Append the stacktrace provided by glog as the top Exception object,
-	// since it provides information about when glog was invoked in the code
-	trace := stacktrace.ExtractFrames(e.StackTrace, nil)
-	if trace != nil {
+	// since it provides information about when glog was invoked in the code.
+	// Warnings are captured as messages instead, so they are grouped
+	// separately from errors.
+	pcs := e.StackTrace
+	if skip > 0 && skip < len(pcs) {
+		pcs = pcs[skip:]
+	}
+	trace := stacktrace.ExtractFrames(pcs, nil)
+	glogIndex := -1
+	if trace != nil && s.Level != sentry.LevelWarning && s.Level != sentry.LevelInfo {
+		glogIndex = len(s.Exception)
 		// Add exception for top-level glog message, if we did not find any
 		// stacktrace data via ErrorArgs.
 		var msgType, msgValue string
@@ -239,22 +752,203 @@ func FromGlogEvent(e glog.Event) (*sentry.Event, string) {
 		})
 	}
 
-	// Reverse the order of the Exception array
-	reverse(s.Exception)
+	// Reverse the order of the Exception array, so that the glog invocation
+	// comes first, followed by the innermost error and ending with the
+	// outermost error, unless the natural unwrap order was requested.
+	if !*sentryRawExceptionOrder {
+		reverse(s.Exception)
+		if glogIndex != -1 {
+			glogIndex = len(s.Exception) - 1 - glogIndex
+		}
+	}
+	if len(s.Exception) > 0 {
+		s.Extra["ExceptionOrigins"] = exceptionOrigins(len(s.Exception), glogIndex)
+	}
+
+	for i := range s.Exception {
+		s.Exception[i].Type = transformTitle(s.Exception[i].Type)
+	}
+
+	// Group errors caused by a registered sentinel error by its key,
+	// unless a fingerprint was explicitly provided.
+	if len(s.Fingerprint) == 0 {
+		if key, ok := sentinelFingerprintKey(errs); ok {
+			s.Fingerprint = []string{key}
+		}
+	}
+
+	// Group validation errors by the set of fields which failed,
+	// unless a fingerprint was explicitly provided.
+	if len(validation) > 0 {
+		s.Extra["FieldErrors"] = validation
+		if len(s.Fingerprint) == 0 {
+			s.Fingerprint = buildFieldErrorsFingerprint(validation)
+		}
+	}
 
 	// Set the fingerprint based on the stack trace, if option is specified.
 	// This overrides logic in Sentry which will take the specific error
 	// message in to account. It instead will be identified by the filename,
-	// method name, and line number.
-	if len(s.Fingerprint) == 0 && *sentryFingerprinting {
-		s.Fingerprint = buildFingerprint(s.Exception)
+	// method name, and line number. Without any in-app frames to identify it
+	// by, it is grouped by its message instead.
+	if len(s.Fingerprint) == 0 && *sentryFingerprinting && len(s.Exception) > 0 {
+		if s.Fingerprint = buildFingerprint(s.Exception); len(s.Fingerprint) == 0 {
+			s.Fingerprint = buildMessageFingerprint(s.Message)
+		}
+	}
+
+	// Without a stack trace to group by, group by the normalized message, if
+	// option is specified.
+	if len(s.Fingerprint) == 0 && *sentryMessageFingerprinting && !hasInAppFrames(s.Exception) {
+		s.Fingerprint = buildMessageFingerprint(s.Message)
 	}
 
 	if len(data) > 0 {
 		s.Extra["Data"] = data
 	}
 
-	return s, targetDsn
+	return s, targetDsns
+}
+
+// appendDsns appends the DSNs which aren't already in the list.
+func appendDsns(list []string, dsns ...string) []string {
+	for _, dsn := range dsns {
+		found := false
+		for _, d := range list {
+			if d == dsn {
+				found = true
+				break
+			}
+		}
+		if !found && dsn != "" {
+			list = append(list, dsn)
+		}
+	}
+	return list
+}
+
+// startupEvent returns the event sent when capturing errors for the project
+// starts, if WithStartupEvent is set.
+func startupEvent(project string) *sentry.Event {
+	s := sentry.NewEvent()
+	s.Level = sentry.LevelInfo
+	s.Message = "Started capturing errors for " + project
+	s.ServerName = hostname
+	s.Logger = stacktrace.GopathRelativeFile(os.Args[0])
+	setTag(s, "startup", "true")
+	return s
+}
+
+// The most breadcrumbs Sentry accepts for an event.
+const maxBreadcrumbs = 100
+
+// setTag sets a tag on the event, initializing its tags if necessary.
+func setTag(s *sentry.Event, key, value string) {
+	if s.Tags == nil {
+		s.Tags = map[string]string{}
+	}
+	s.Tags[key] = value
+}
+
+// setTagIfAbsent sets a tag on the event unless it is already set, such as by
+// an error earlier in the chain.
+func setTagIfAbsent(s *sentry.Event, key, value string) {
+	if _, ok := s.Tags[key]; !ok {
+		setTag(s, key, value)
+	}
+}
+
+// chainedError is an error found while unwrapping, at the given depth.
+type chainedError struct {
+	err   error
+	depth int
+	// via is the method used to unwrap the error from its parent.
+	via string
+}
+
+// unwrap returns the errors wrapped by err, in order.
+func unwrap(err error, depth int) []chainedError {
+	var r []chainedError
+	switch previous := err.(type) {
+	case interface{ Unwrap() error }:
+		r = append(r, chainedError{previous.Unwrap(), depth, "Unwrap() error"})
+	case interface{ Unwrap() []error }:
+		for _, e := range previous.Unwrap() {
+			r = append(r, chainedError{e, depth, "Unwrap() []error"})
+		}
+	case interface{ Cause() error }:
+		r = append(r, chainedError{previous.Cause(), depth, "Cause() error"})
+	}
+
+	// Skip any nil errors
+	n := 0
+	for _, c := range r {
+		if !isNilError(c.err) {
+			r[n] = c
+			n++
+		}
+	}
+	return r[:n]
+}
+
+// isNilError returns whether the error is nil, or is a nil pointer (or other
+// nillable type) which implements error, since calling its methods may panic.
+func isNilError(err error) bool {
+	if err == nil {
+		return true
+	}
+	v := reflect.ValueOf(err)
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan, reflect.Interface:
+		return v.IsNil()
+	}
+	return false
+}
+
+// limitExceptions limits the exceptions of the event to the given number,
+// keeping the innermost causes (which are last). The types and values of any
+// omitted exceptions are summarized in the extra data of the event.
+func limitExceptions(s *sentry.Event, max int) {
+	if max < 1 {
+		max = 1
+	}
+	n := len(s.Exception) - max
+	if n <= 0 {
+		return
+	}
+
+	var omitted []string
+	for _, ex := range s.Exception[:n] {
+		omitted = append(omitted, prependMessage(ex.Type, ex.Value))
+	}
+	s.Extra["OmittedExceptions"] = omitted
+	s.Exception = s.Exception[n:]
+}
+
+// The origins of the exceptions of an event. The stack trace of the glog
+// invocation and those of the errors passed to it may be from different
+// goroutines, so they don't necessarily form a single call path.
+const (
+	// originGlogCall is the exception for the glog invocation.
+	originGlogCall = "glog-call"
+	// originError is an exception for an error, from where it was created.
+	originError = "error-origin"
+)
+
+// exceptionOrigins labels the origin of each of the n exceptions of an event,
+// where the exception at glogIndex (if not -1) is for the glog invocation.
+// The Exception of the Sentry client doesn't support a mechanism, so they are
+// attached to the extra data instead.
+func exceptionOrigins(n, glogIndex int) []string {
+	origins := make([]string, n)
+	for i := range origins {
+		if i == glogIndex {
+			origins[i] = originGlogCall
+		} else {
+			origins[i] = originError
+		}
+	}
+	return origins
 }
 
 func reverse(e []sentry.Exception) {
@@ -0,0 +1,157 @@
+package sentry
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"runtime/debug"
+	"testing"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/yext/glog"
+	"github.com/yext/yerrors"
+)
+
+func TestEnvironmentFromHostname(t *testing.T) {
+	re := regexp.MustCompile(`^(prod|stg)-`)
+	assert.Equal(t, "prod", environmentFromHostname(re, "prod-server-01"))
+	assert.Equal(t, "stg", environmentFromHostname(re, "stg-server-02"))
+	assert.Equal(t, "", environmentFromHostname(re, "dev-server-03"), "non-matching hostname")
+
+	re = regexp.MustCompile(`^[a-z]+`)
+	assert.Equal(t, "prod", environmentFromHostname(re, "prod-server-01"), "entire match without groups")
+}
+
+func TestBuildClientOptionsEnvironment(t *testing.T) {
+	defer func(old string) { *sentryEnvironmentFromHostname = old }(*sentryEnvironmentFromHostname)
+	opts := sentry.ClientOptions{Environment: "static"}
+	t.Setenv("SENTRY_ENVIRONMENT", "")
+	environment := func() string {
+		clientOpts, err := buildClientOptions("", opts)
+		assert.NoError(t, err)
+		return clientOpts.Environment
+	}
+
+	*sentryEnvironmentFromHostname = ""
+	assert.Equal(t, "static", environment(), "disabled by default")
+
+	*sentryEnvironmentFromHostname = "^" + regexp.QuoteMeta(hostname) + "$"
+	assert.Equal(t, hostname, environment(), "derived from hostname")
+
+	*sentryEnvironmentFromHostname = "^$"
+	assert.Equal(t, "static", environment(), "falls back when not matched")
+
+	*sentryEnvironmentFromHostname = "("
+	_, err := buildClientOptions("", opts)
+	assert.Error(t, err, "invalid pattern")
+}
+
+func TestResolveEnvironment(t *testing.T) {
+	defer func(old string) { *sentryEnvironmentFromHostname = old }(*sentryEnvironmentFromHostname)
+	*sentryEnvironmentFromHostname = "^" + regexp.QuoteMeta(hostname) + "$"
+	opts := sentry.ClientOptions{Environment: "static"}
+	resolve := func(opts sentry.ClientOptions) string {
+		env, err := resolveEnvironment(opts)
+		assert.NoError(t, err)
+		return env
+	}
+
+	t.Setenv("SENTRY_ENVIRONMENT", "env")
+	assert.Equal(t, "env", resolve(opts), "environment variable comes first")
+
+	t.Setenv("SENTRY_ENVIRONMENT", "")
+	assert.Equal(t, hostname, resolve(opts), "then the hostname")
+
+	*sentryEnvironmentFromHostname = ""
+	assert.Equal(t, "static", resolve(opts), "then the client options")
+
+	assert.Empty(t, resolve(sentry.ClientOptions{}), "empty if none are set")
+}
+
+func TestCaptureErrorsInvalidEnvironmentPattern(t *testing.T) {
+	defer func(old string) { *sentryEnvironmentFromHostname = old }(*sentryEnvironmentFromHostname)
+	t.Setenv("SENTRY_ENVIRONMENT", "")
+	*sentryEnvironmentFromHostname = "("
+
+	comm := make(chan glog.Event)
+	close(comm)
+	err := CaptureErrorsE("example", []string{""}, sentry.ClientOptions{}, comm)
+	assert.ErrorContains(t, err, "-sentryEnvironmentFromHostname")
+}
+
+func TestModuleVersions(t *testing.T) {
+	info := &debug.BuildInfo{
+		Deps: []*debug.Module{
+			{Path: "github.com/yext/glog", Version: "v1.2.0"},
+			{Path: "github.com/yext/yerrors", Version: "v0.1.0", Replace: &debug.Module{Path: "../yerrors"}},
+			{Path: "github.com/yext/other", Version: "v0.1.0", Replace: &debug.Module{Path: "github.com/fork/other", Version: "v0.2.0"}},
+			{Path: "golang.org/x/time", Version: "v0.3.0"},
+		},
+	}
+
+	assert.Equal(t, map[string]string{
+		"github.com/yext/glog":    "v1.2.0",
+		"github.com/yext/yerrors": "../yerrors",
+		"github.com/yext/other":   "github.com/fork/other v0.2.0",
+	}, moduleVersions(info, []string{"github.com/yext/", "github.com/yext/glog"}))
+	assert.Empty(t, moduleVersions(info, nil), "only allowed modules are included")
+	assert.Nil(t, moduleVersions(nil, []string{"github.com/yext/"}), "missing build info is ignored")
+}
+
+func TestWithTenantDsns(t *testing.T) {
+	SetTenantDsns(map[string]string{"a": "dsn-2", "b": "dsn-1", "c": "dsn-3"})
+	defer SetTenantDsns(nil)
+
+	dsns := []string{"dsn-1"}
+	assert.Equal(t, []string{"dsn-1", "dsn-2", "dsn-3"}, withTenantDsns(dsns))
+	assert.Equal(t, []string{"dsn-1"}, dsns, "the DSNs passed are not modified")
+}
+
+func TestBuildFingerprintWithoutStacktrace(t *testing.T) {
+	assert.Empty(t, buildFingerprint(nil))
+	assert.Empty(t, buildFingerprint([]sentry.Exception{{Type: "raw error"}}))
+	assert.Empty(t, buildFingerprint([]sentry.Exception{{Type: "raw error", Stacktrace: &sentry.Stacktrace{}}}))
+}
+
+func TestSplitMessage(t *testing.T) {
+	for _, tc := range []struct {
+		msg, msgType, msgValue string
+	}{
+		{"test message", "test message", ""},
+		{"test message: more details", "test message", "more details"},
+		{"test message: more: details\nsecond line", "test message", "more: details"},
+		{"dial tcp http://x: connection refused", "dial tcp", "http://x: connection refused"},
+		{`Get "https://example.com/a?b=c": EOF`, "Get", `"https://example.com/a?b=c": EOF`},
+		{"http://x: connection refused", "connection refused", ""},
+		{"request timed out at 12:00:05: retrying", "request timed out at", "12:00:05: retrying"},
+		{"12:00:05: failed to start", "failed to start", ""},
+		{"2006-01-02 15:04:05.123 server: failed to start", "server", "failed to start"},
+		{"dial tcp 10.0.0.1:80: connection refused", "dial tcp 10.0.0.1:80", "connection refused"},
+		{"dial tcp localhost:5432: connection refused", "dial tcp localhost:5432", "connection refused"},
+	} {
+		msgType, msgValue := splitMessage(tc.msg)
+		assert.Equal(t, tc.msgType, msgType, tc.msg)
+		assert.Equal(t, tc.msgValue, msgValue, tc.msg)
+	}
+}
+
+func TestHeadline(t *testing.T) {
+	root := errors.New("not found")
+	for _, tc := range []struct {
+		name     string
+		err      error
+		headline string
+	}{
+		{"unwrapped", root, "not found"},
+		{"yerrors", yerrors.Wrap(yerrors.Errorf("loading user: %w", root)), "loading user: not found"},
+		{"fmt", fmt.Errorf("handling request: %w", fmt.Errorf("loading user: %w", root)), "loading user: not found"},
+		{"errors.Join", fmt.Errorf("handling request: %w", errors.Join(fmt.Errorf("loading user: %w", root), io.EOF)),
+			"loading user: not found"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.headline, headline(tc.err))
+		})
+	}
+}
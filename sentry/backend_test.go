@@ -1,10 +1,17 @@
 package sentry_test
 
 import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"runtime"
+	"sort"
 	"strings"
 	"testing"
 	"time"
@@ -12,6 +19,7 @@ import (
 	sentrygo "github.com/getsentry/sentry-go"
 	"github.com/kr/pretty"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/yext/glog"
 	"github.com/yext/glog-contrib/sentry"
 	"github.com/yext/yerrors"
@@ -455,3 +463,336 @@ func TestGlogYerrorsWrappedEvent(t *testing.T) {
 	assert.Equal(t, errorWrappedLine, ex.Stacktrace.Frames[1].Lineno, "second frame line number matches")
 	assert.Equal(t, errorLine, ex.Stacktrace.Frames[2].Lineno, "third frame line number matches")
 }
+
+func TestRawExceptionOrder(t *testing.T) {
+	flag.Set("sentryRawExceptionOrder", "true")
+	defer flag.Set("sentryRawExceptionOrder", "false")
+
+	err := fmt.Errorf("outer: %w", errors.New("inner"))
+	e, _ := sentry.FromGlogEvent(newErrorEvent("outer: inner", glog.ErrorArg{Error: err}))
+
+	assert.Len(t, e.Exception, 3, "three exceptions (two from the err, last is from glog)")
+	assert.Equal(t, "outer", e.Exception[0].Type, "outermost error is first")
+	assert.Equal(t, "inner", e.Exception[1].Type, "innermost error is second")
+	assert.NotNil(t, e.Exception[2].Stacktrace, "glog invocation is last")
+	assert.Equal(t, "TestRawExceptionOrder", e.Exception[2].Stacktrace.Frames[0].Function)
+}
+
+type joinedErrors []error
+
+func (e joinedErrors) Error() string   { return fmt.Sprintf("%d errors", len(e)) }
+func (e joinedErrors) Unwrap() []error { return e }
+
+func TestMaxExceptions(t *testing.T) {
+	var errs joinedErrors
+	for i := 1; i <= 30; i++ {
+		errs = append(errs, fmt.Errorf("error %d", i))
+	}
+
+	e, _ := sentry.FromGlogEvent(newErrorEvent("30 errors", glog.ErrorArg{Error: errs}))
+	assert.Len(t, e.Exception, 32, "all joined errors are unwrapped by default")
+	assert.NotContains(t, e.Extra, "OmittedExceptions")
+
+	flag.Set("sentryMaxExceptions", "5")
+	defer flag.Set("sentryMaxExceptions", "0")
+
+	e, _ = sentry.FromGlogEvent(newErrorEvent("30 errors", glog.ErrorArg{Error: errs}))
+	assert.Len(t, e.Exception, 5)
+	assert.Equal(t, "TestMaxExceptions", e.Exception[0].Stacktrace.Frames[0].Function, "glog invocation is kept")
+	assert.Equal(t, "error 30", e.Exception[1].Type, "innermost causes are kept")
+	assert.Equal(t, "error 27", e.Exception[4].Type)
+	assert.Len(t, e.Extra["OmittedExceptions"], 27, "remaining exceptions are summarized")
+	assert.Contains(t, e.Extra["OmittedExceptions"], "error 1")
+	assert.Contains(t, e.Extra["OmittedExceptions"], "30 errors", "the outermost error is summarized")
+}
+
+func TestMaxExceptionsKeepsRootCause(t *testing.T) {
+	err := yerrors.New("root cause")
+	for i := 1; i < 8; i++ {
+		err = fmt.Errorf("wrap %d: %w", i, err)
+	}
+
+	flag.Set("sentryMaxExceptions", "3")
+	defer flag.Set("sentryMaxExceptions", "0")
+
+	e, _ := sentry.FromGlogEvent(newErrorEvent("wrapped", glog.ErrorArg{Error: err}))
+	assert.Len(t, e.Exception, 3)
+	assert.Equal(t, "TestMaxExceptionsKeepsRootCause", e.Exception[0].Stacktrace.Frames[0].Function, "glog invocation is kept")
+	assert.Equal(t, "root cause", e.Exception[1].Type, "the root cause survives truncation")
+	assert.Equal(t, "wrap 1", e.Exception[2].Type, "followed by the error wrapping it")
+	assert.Len(t, e.Extra["OmittedExceptions"], 6, "the outer wrappers are summarized")
+}
+
+func TestSetMaxErrorDepth(t *testing.T) {
+	err := yerrors.New("root cause")
+	for i := 1; i < 15; i++ {
+		err = yerrors.Wrap(err)
+	}
+
+	e, _ := sentry.FromGlogEvent(newErrorEvent("root cause", glog.ErrorArg{Error: err}))
+	assert.Len(t, e.Exception, 11, "10 errors are unwrapped by default, with the glog invocation")
+
+	sentry.SetMaxErrorDepth(20)
+	defer sentry.SetMaxErrorDepth(10)
+
+	e, _ = sentry.FromGlogEvent(newErrorEvent("root cause", glog.ErrorArg{Error: err}))
+	assert.Len(t, e.Exception, 16, "all 15 errors are unwrapped, with the glog invocation")
+}
+
+// slowError is an error with a slow Error method.
+type slowError struct {
+	err error
+}
+
+func (e slowError) Error() string {
+	time.Sleep(10 * time.Millisecond)
+	return "slow error"
+}
+
+func (e slowError) Unwrap() error { return e.err }
+
+func TestConversionDeadline(t *testing.T) {
+	var err error
+	for i := 0; i < 8; i++ {
+		err = slowError{err}
+	}
+
+	e, _ := sentry.FromGlogEvent(newErrorEvent("slow error", glog.ErrorArg{Error: err}))
+	assert.Len(t, e.Exception, 9, "all errors are converted by default")
+	assert.NotContains(t, e.Tags, "conversion_truncated")
+
+	flag.Set("sentryConversionDeadline", "25ms")
+	defer flag.Set("sentryConversionDeadline", "0")
+
+	e, _ = sentry.FromGlogEvent(newErrorEvent("slow error", glog.ErrorArg{Error: err}, sentry.Fingerprint("slow")))
+	assert.Less(t, len(e.Exception), 9, "conversion stops at the deadline")
+	assert.Equal(t, "true", e.Tags["conversion_truncated"])
+	assert.Equal(t, []string{"slow"}, e.Fingerprint, "attributes are still applied")
+	assert.Equal(t, "TestConversionDeadline", e.Exception[0].Stacktrace.Frames[0].Function, "glog invocation is included")
+}
+
+// causeError wraps an error using the Cause method.
+type causeError struct {
+	msg   string
+	cause error
+}
+
+func (e causeError) Error() string { return e.msg + ": " + e.cause.Error() }
+func (e causeError) Cause() error  { return e.cause }
+
+func TestErrorChain(t *testing.T) {
+	envelopes := make(chan []byte, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		envelopes <- body
+	}))
+	defer server.Close()
+	dsn := strings.Replace(server.URL, "://", "://public@", 1) + "/1"
+
+	err := causeError{"cause", joinedErrors{
+		fmt.Errorf("wrapped: %w", errors.New("inner")),
+		errors.New("other"),
+	}}
+	capture := func() []*sentrygo.Event {
+		transport := &transportMock{}
+		comm := make(chan glog.Event, 1)
+		comm <- newErrorEvent("test message", glog.ErrorArg{Error: err})
+		close(comm)
+		sentry.CaptureErrors("example", []string{dsn}, sentrygo.ClientOptions{Transport: transport}, comm)
+		return transport.Events()
+	}
+
+	require.Len(t, capture(), 1)
+	assert.Len(t, envelopes, 0, "not attached by default")
+
+	flag.Set("sentryErrorChain", "true")
+	defer flag.Set("sentryErrorChain", "false")
+
+	events := capture()
+	require.Len(t, events, 1)
+	assert.NotContains(t, events[0].Extra, "ErrorChain", "not in the extra data")
+
+	require.Len(t, envelopes, 1)
+	parts := bytes.SplitN(<-envelopes, []byte("\n"), 3)
+	require.Len(t, parts, 3, "envelope header, item header and payload")
+	var header, item map[string]interface{}
+	require.NoError(t, json.Unmarshal(parts[0], &header))
+	require.NoError(t, json.Unmarshal(parts[1], &item))
+	assert.Equal(t, string(events[0].EventID), header["event_id"], "the chain is attached to the event")
+	assert.Equal(t, "attachment", item["type"])
+	assert.Equal(t, "error_chain.json.gz", item["filename"])
+	assert.Equal(t, "application/gzip", item["content_type"])
+	length, ok := item["length"].(float64)
+	require.True(t, ok)
+	require.Greater(t, len(parts[2]), int(length))
+	assert.Equal(t, byte('\n'), parts[2][int(length)], "the payload is its length")
+
+	r, gzErr := gzip.NewReader(bytes.NewReader(parts[2][:int(length)]))
+	require.NoError(t, gzErr)
+	b, readErr := ioutil.ReadAll(r)
+	require.NoError(t, readErr)
+	assert.JSONEq(t, `[
+		{"depth": 0, "type": "sentry_test.causeError", "message": "cause: 2 errors"},
+		{"depth": 1, "type": "sentry_test.joinedErrors", "message": "2 errors", "via": "Cause() error"},
+		{"depth": 2, "type": "*fmt.wrapError", "message": "wrapped: inner", "via": "Unwrap() []error"},
+		{"depth": 3, "type": "*errors.errorString", "message": "inner", "via": "Unwrap() error"},
+		{"depth": 2, "type": "*errors.errorString", "message": "other", "via": "Unwrap() []error"}
+	]`, string(b))
+}
+
+// recursiveErrorEvent builds an error event from depth levels of recursion.
+func recursiveErrorEvent(depth int) glog.Event {
+	if depth > 1 {
+		return recursiveErrorEvent(depth - 1)
+	}
+	return newErrorEvent("test message")
+}
+
+func TestFingerprintRecursion(t *testing.T) {
+	flag.Set("sentryFingerprinting", "true")
+	defer flag.Set("sentryFingerprinting", "false")
+
+	var events []*sentrygo.Event
+	for _, depth := range []int{2, 5} {
+		e, _ := sentry.FromGlogEvent(recursiveErrorEvent(depth))
+		events = append(events, e)
+	}
+	shallow, deep := events[0], events[1]
+
+	assert.NotEmpty(t, deep.Fingerprint)
+	assert.Equal(t, shallow.Fingerprint, deep.Fingerprint, "repeated frames do not change the fingerprint")
+	assert.True(t, sort.StringsAreSorted(deep.Fingerprint), "entries are sorted")
+	seen := map[string]bool{}
+	for _, entry := range deep.Fingerprint {
+		assert.False(t, seen[entry], "entries are unique: "+entry)
+		seen[entry] = true
+	}
+}
+
+func TestExceptionOrigins(t *testing.T) {
+	errCh := make(chan error)
+	go func() {
+		errCh <- yerrors.New("failed in another goroutine")
+	}()
+	e, _ := sentry.FromGlogEvent(newErrorEvent("test message", glog.ErrorArg{Error: <-errCh}))
+
+	if assert.Len(t, e.Exception, 2) {
+		glogFrames, errFrames := e.Exception[0].Stacktrace.Frames, e.Exception[1].Stacktrace.Frames
+		assert.True(t, strings.HasSuffix(glogFrames[len(glogFrames)-1].Function, "TestExceptionOrigins"), "logged in the test")
+		assert.True(t, strings.HasSuffix(errFrames[len(errFrames)-1].Function, "TestExceptionOrigins.func1"), "created in the goroutine")
+	}
+	assert.Equal(t, []string{"glog-call", "error-origin"}, e.Extra["ExceptionOrigins"],
+		"the exceptions are labeled by where their stack traces came from")
+}
+
+func TestSetMaxDataDepth(t *testing.T) {
+	data := map[string]interface{}{
+		"id": 1,
+		"nested": map[string]interface{}{
+			"list": []interface{}{"a", map[string]int{"b": 2}},
+			"map": map[string]interface{}{
+				"deeper": map[string]interface{}{"c": 3},
+			},
+		},
+	}
+
+	e, _ := sentry.FromGlogEvent(newErrorEvent("test message", data))
+	assert.Equal(t, data, e.Extra["Data"], "not truncated by default")
+
+	sentry.SetMaxDataDepth(3)
+	defer sentry.SetMaxDataDepth(0)
+	e, _ = sentry.FromGlogEvent(newErrorEvent("test message", data))
+	assert.Equal(t, map[string]interface{}{
+		"id": 1,
+		"nested": map[string]interface{}{
+			"list": []interface{}{"a", "[truncated: depth]"},
+			"map": map[string]interface{}{
+				"deeper": "[truncated: depth]",
+			},
+		},
+	}, e.Extra["Data"])
+}
+
+func TestEventFingerprint(t *testing.T) {
+	newEvent := func(msg string) glog.Event {
+		return newErrorEvent(msg)
+	}
+	first, second := sentry.EventFingerprint(newEvent("first message")), sentry.EventFingerprint(newEvent("second message"))
+	assert.NotEmpty(t, first)
+	assert.Equal(t, first, second, "events from the same call site are grouped together")
+	assert.NotEqual(t, first, sentry.EventFingerprint(newErrorEvent("first message")), "other call sites are not")
+
+	assert.Equal(t, []string{"custom"}, sentry.EventFingerprint(newErrorEvent("test message", sentry.Fingerprint("custom"))),
+		"explicit fingerprints take precedence")
+}
+
+func TestSetMessagePrefixParser(t *testing.T) {
+	e, _ := sentry.FromGlogEvent(newErrorEvent("E1016 03:51:14.555395 file.go:37] test message"))
+	assert.Equal(t, "test message", e.Message, "glog headers are removed by default")
+
+	sentry.SetMessagePrefixParser(func(msg []byte) string {
+		if i := strings.Index(string(msg), " | "); i != -1 {
+			return string(msg[i+3:])
+		}
+		return string(msg)
+	})
+	defer sentry.SetMessagePrefixParser(nil)
+	e, _ = sentry.FromGlogEvent(newErrorEvent("E 2026-10-16 file.go:37 | test message"))
+	assert.Equal(t, "test message", e.Message, "custom headers are removed")
+}
+
+func TestBuildLevel(t *testing.T) {
+	for severity, level := range map[string]sentrygo.Level{
+		"INFO":    sentrygo.LevelInfo,
+		"WARNING": sentrygo.LevelWarning,
+		"ERROR":   sentrygo.LevelError,
+		"FATAL":   sentrygo.LevelFatal,
+		"UNKNOWN": sentrygo.LevelError,
+		"":        sentrygo.LevelError,
+	} {
+		e := newErrorEvent("test message")
+		e.Severity = severity
+		s, _ := sentry.FromGlogEvent(e)
+		assert.Equal(t, level, s.Level, severity)
+	}
+}
+
+// nilPointerError is an error whose methods panic on a nil receiver.
+type nilPointerError struct {
+	msg string
+}
+
+func (e *nilPointerError) Error() string { return e.msg }
+
+func TestNilErrors(t *testing.T) {
+	var typedNil *nilPointerError
+	for name, err := range map[string]error{
+		"nil interface": nil,
+		"typed nil":     typedNil,
+	} {
+		e, _ := sentry.FromGlogEvent(newErrorEvent("test message", glog.ErrorArg{Error: err}))
+		assert.Equal(t, "test message", e.Message, name)
+		if assert.Len(t, e.Exception, 1, name+": only the glog invocation") {
+			assert.Equal(t, "test message", e.Exception[0].Type, name)
+		}
+	}
+
+	e, _ := sentry.FromGlogEvent(newErrorEvent("test message", glog.ErrorArg{Error: fmt.Errorf("wrapped: %w", typedNil)}))
+	assert.Len(t, e.Exception, 2, "typed nils wrapped by errors are skipped")
+}
+
+func TestFingerprintWithoutStacktrace(t *testing.T) {
+	flag.Set("sentryFingerprinting", "true")
+	defer flag.Set("sentryFingerprinting", "false")
+
+	// Without the stack trace of the glog invocation, the top exception is
+	// the raw error, which has none.
+	e, _ := sentry.FromGlogEvent(glog.Event{
+		Severity: "ERROR",
+		Message:  []byte("failed after 3 attempts"),
+		Data:     []interface{}{glog.ErrorArg{Error: errors.New("failed after 3 attempts")}},
+	})
+	assert.NotEmpty(t, e.Exception)
+	assert.Equal(t, "message", e.Fingerprint[0], "grouped by the message instead")
+	assert.Equal(t, e.Fingerprint, sentry.EventFingerprint(glog.Event{Severity: "ERROR", Message: []byte("failed after 4 attempts")}))
+}
@@ -0,0 +1,449 @@
+package sentry
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/yext/glog"
+	"golang.org/x/time/rate"
+)
+
+// capturer routes converted glog events to the hub for their DSN,
+// applying any Options provided to CaptureErrors.
+type capturer struct {
+	options
+	hubs       map[string]*sentry.Hub
+	primaryHub *sentry.Hub
+	dedup      *deduper
+	detailed   *deduper
+	pacers     map[*sentry.Hub]*pacer
+	metrics    *metricAggregator
+	inFlight   chan struct{}
+	limiter    *rate.Limiter
+	pool       *workerPool
+	// The Sentry level of the minimum severity.
+	minLevel sentry.Level
+	// The number of events dropped by the limiter since one was sent.
+	rateLimited int64
+	// The error chains being attached to events.
+	attachments sync.WaitGroup
+}
+
+// newCapturer returns a capturer with the options applied, or an error if
+// they are invalid.
+func newCapturer(opts []Option) (*capturer, error) {
+	c := &capturer{
+		hubs:   make(map[string]*sentry.Hub),
+		pacers: make(map[*sentry.Hub]*pacer),
+	}
+	c.sampleRate = 1
+	c.flushTimeout = time.Second
+	c.minSeverity = "ERROR"
+	c.counters = noopMetrics{}
+	for _, opt := range opts {
+		opt(&c.options)
+	}
+	min, ok := severityLevel(c.minSeverity)
+	if !ok {
+		return nil, fmt.Errorf("unknown minimum severity %q", c.minSeverity)
+	}
+	c.minLevel = min
+	if c.dedupWindow > 0 {
+		c.dedup = newDeduper(c.dedupWindow)
+	}
+	if c.metricWindow > 0 {
+		c.metrics = newMetricAggregator(c.metricLimit, c.metricWindow)
+	}
+	if c.detailOnce > 0 {
+		c.detailed = newDeduper(c.detailOnce)
+	}
+	if c.maxInFlight > 0 {
+		c.inFlight = make(chan struct{}, c.maxInFlight)
+	}
+	if c.maxPerSec > 0 {
+		c.limiter = rate.NewLimiter(rate.Limit(c.maxPerSec), c.maxPerSec)
+	}
+	if c.workers > 0 {
+		c.pool = newWorkerPool(c.workers, c.workerQueue, c.capture)
+	}
+	return c, nil
+}
+
+// addHub adds the hub for the DSN, the first of which is the primary hub.
+func (c *capturer) addHub(dsn string, hub *sentry.Hub) {
+	if c.primaryHub == nil {
+		c.primaryHub = hub
+	}
+	c.hubs[dsn] = hub
+	if c.minInterval > 0 {
+		c.pacers[hub] = newPacer(c.minInterval, c.paceBuffer)
+	}
+}
+
+// close sends any events which are waiting to be sent.
+func (c *capturer) close() {
+	// The workers may still be sending to the pacers.
+	if c.pool != nil {
+		c.pool.close()
+	}
+	for _, p := range c.pacers {
+		p.close()
+	}
+	if c.metrics != nil {
+		c.metrics.close()
+	}
+	if c.profiler != nil {
+		c.profiler.wait()
+	}
+	c.attachments.Wait()
+}
+
+// The Sentry levels, from least to most severe.
+var levelRanks = map[sentry.Level]int{
+	sentry.LevelDebug:   0,
+	sentry.LevelInfo:    1,
+	sentry.LevelWarning: 2,
+	sentry.LevelError:   3,
+	sentry.LevelFatal:   4,
+}
+
+// captures returns whether events of the glog severity are captured, which
+// are those with a known severity whose Sentry level is at least that of the
+// minimum severity. Fatal events are always captured.
+func (c *capturer) captures(severity string) bool {
+	level, ok := severityLevel(severity)
+	if !ok {
+		return false
+	}
+	return levelRanks[level] >= levelRanks[c.minLevel] || level == sentry.LevelFatal
+}
+
+// enqueue captures the glog event on the worker pool, if there is one, or
+// otherwise immediately. Fatal events are always captured immediately, since
+// the process is about to exit.
+func (c *capturer) enqueue(glogEvent glog.Event) {
+	c.counters.Received()
+	level, _ := severityLevel(glogEvent.Severity)
+	if c.pool == nil || level == sentry.LevelFatal {
+		c.capture(glogEvent)
+		return
+	}
+	if !c.pool.offer(glogEvent) {
+		// The event isn't converted, so as not to hold up the caller.
+		e := sentry.NewEvent()
+		e.Message = removeGlogPrefix(glogEvent.Message)
+		e.Level = level
+		c.dropped(e, nil, DropQueueFull)
+	}
+}
+
+// capture converts the glog event and sends it to Sentry.
+func (c *capturer) capture(glogEvent glog.Event) {
+	// A panic converting one event shouldn't stop the capture of others.
+	defer func() {
+		if r := recover(); r != nil {
+			c.counters.Failed()
+			// Don't use glog, or we'll just end up in an infinite loop
+			log.Printf("Recovered from panic sending error to Sentry: %v for glog event with message: %s",
+				r, string(glogEvent.Message))
+		}
+	}()
+
+	e, targetDsns := fromGlogEvent(glogEvent)
+	forced := isForced(glogEvent.Data)
+	if !forced && len(c.errorFilter) > 0 && !c.matchesErrorFilter(glogEvent.Data) {
+		c.dropped(e, eventFingerprint(e), DropFiltered)
+		return
+	}
+	for k, v := range c.envTags {
+		if _, ok := e.Tags[k]; !ok {
+			setTag(e, k, v)
+		}
+	}
+	if c.runtimeTags {
+		setRuntimeTags(e)
+	}
+	if len(c.modules) > 0 {
+		e.Extra["Modules"] = c.modules
+	}
+	fp := eventFingerprint(e)
+
+	if !forced && c.sampleRate < 1 && rand.Float64() >= c.sampleRate {
+		c.dropped(e, fp, DropSampled)
+		return
+	}
+
+	// Events above the threshold for their fingerprint are only counted.
+	if !forced && c.metrics != nil && c.metrics.aggregate(e, fp) {
+		c.dropped(e, fp, DropAggregated)
+		return
+	}
+
+	// Repeats of an event within the dedup window are either
+	// dropped, or captured at a lower level if so configured.
+	// The first occurrence after the window has the number of times the
+	// event was seen within it.
+	if !forced && c.dedup != nil {
+		repeat, timesSeen := c.dedup.observe(strings.Join(fp, "\n"))
+		if repeat {
+			if c.repeatLevel == "" {
+				c.dropped(e, fp, DropDuplicate)
+				return
+			}
+			e.Level = c.repeatLevel
+		} else if timesSeen > 1 {
+			e.Extra["TimesSeen"] = timesSeen
+		}
+	}
+
+	// Only the first occurrence of an event within the window has full detail.
+	if c.detailed != nil {
+		if len(e.Fingerprint) == 0 {
+			e.Fingerprint = fp
+		}
+		if c.detailed.repeat(strings.Join(fp, "\n")) && !forced {
+			e = minimalEvent(e)
+		}
+	}
+
+	// Events beyond the rate are dropped, and counted on the next one sent.
+	if !forced && c.limiter != nil && !c.limiter.Allow() {
+		atomic.AddInt64(&c.rateLimited, 1)
+		c.dropped(e, fp, DropRateLimited)
+		return
+	}
+	if n := atomic.SwapInt64(&c.rateLimited, 0); n > 0 {
+		e.Extra["RateLimited"] = int(n)
+	}
+
+	if c.beforeSend != nil {
+		original := e
+		if e = c.beforeSend(e); e == nil {
+			c.dropped(original, fp, DropBeforeSend)
+			return
+		}
+	}
+
+	// Forced and fatal events are sent immediately rather than waiting their turn.
+	level, _ := severityLevel(glogEvent.Severity)
+	fatal := level == sentry.LevelFatal
+	scope := scopeFromData(glogEvent.Data)
+	var chain []byte
+	if *sentryErrorChain {
+		var err error
+		if chain, err = compressErrorChain(errorChain(glogEvent.Data)); err != nil {
+			log.Printf("Not attaching error chain to Sentry event: %v", err)
+		}
+	}
+	hubs := c.targetHubs(targetDsns)
+	// Each hub captures its own copy, as capturing modifies the event. The
+	// copies are all made before any is sent, since a pacer may capture the
+	// event on its own goroutine while it would otherwise still be copied.
+	events := make([]*sentry.Event, len(hubs))
+	for i := range hubs {
+		events[i] = e
+		if i > 0 {
+			events[i] = copyEvent(e)
+		}
+	}
+	for i, hub := range hubs {
+		c.send(hub, events[i], fp, scope, chain, forced || fatal)
+	}
+	// The process is about to exit, so don't wait to flush a fatal event.
+	if fatal {
+		for _, hub := range hubs {
+			hub.Flush(c.flushTimeout)
+		}
+	}
+}
+
+// targetHubs returns the hubs for the DSNs, in order, with the primary hub
+// in place of DSNs which have no client. Each hub is returned at most once.
+func (c *capturer) targetHubs(dsns []string) []*sentry.Hub {
+	if len(dsns) == 0 {
+		return []*sentry.Hub{c.primaryHub}
+	}
+	var hubs []*sentry.Hub
+	seen := map[*sentry.Hub]bool{}
+	for _, dsn := range dsns {
+		hub, ok := c.hubs[dsn]
+		if !ok {
+			hub = c.primaryHub
+		}
+		if !seen[hub] {
+			seen[hub] = true
+			hubs = append(hubs, hub)
+		}
+	}
+	return hubs
+}
+
+// send captures the event on the hub, waiting its turn with the pacer of the
+// hub unless it's urgent, and attaches the compressed error chain, if any.
+func (c *capturer) send(hub *sentry.Hub, e *sentry.Event, fp []string, scope *sentry.Scope, chain []byte, urgent bool) {
+	var p *pacer
+	if !urgent {
+		p = c.pacers[hub]
+	}
+	// Capture on a new hub if a scope was provided, to avoid
+	// mutating the scope shared by all events for the DSN.
+	if scope != nil {
+		hub = sentry.NewHub(hub.Client(), scope.Clone())
+	}
+	send := func() {
+		if c.inFlight != nil {
+			c.inFlight <- struct{}{}
+			defer func() { <-c.inFlight }()
+		}
+		id := hub.CaptureEvent(e)
+		if id == nil {
+			c.dropped(e, fp, DropClient)
+			return
+		}
+		c.counters.Sent()
+		if c.profiler != nil {
+			c.profiler.attach(hub.Client().Options(), e, *id)
+		}
+		if len(chain) > 0 {
+			c.attachErrorChain(hub.Client().Options(), *id, chain)
+		}
+		if c.onCaptured != nil {
+			c.onCaptured(e, fp)
+		}
+	}
+	if p != nil {
+		if !p.offer(send) {
+			c.dropped(e, fp, DropBufferFull)
+		}
+		return
+	}
+	send()
+}
+
+// copyEvent returns a copy of the event which may be captured independently
+// of it, such that the tags and extra data added by the hub to one aren't
+// added to the other. The stack traces are copied too, as the client adds the
+// source code around each frame.
+func copyEvent(e *sentry.Event) *sentry.Event {
+	c := *e
+	c.EventID = ""
+	c.Breadcrumbs = append([]*sentry.Breadcrumb(nil), e.Breadcrumbs...)
+	c.Fingerprint = append([]string(nil), e.Fingerprint...)
+	c.Exception = append([]sentry.Exception(nil), e.Exception...)
+	for i, ex := range c.Exception {
+		c.Exception[i].Stacktrace = copyStacktrace(ex.Stacktrace)
+	}
+	c.Threads = append([]sentry.Thread(nil), e.Threads...)
+	for i, th := range c.Threads {
+		c.Threads[i].Stacktrace = copyStacktrace(th.Stacktrace)
+	}
+	c.Tags = make(map[string]string, len(e.Tags))
+	for k, v := range e.Tags {
+		c.Tags[k] = v
+	}
+	c.Extra = make(map[string]interface{}, len(e.Extra))
+	for k, v := range e.Extra {
+		c.Extra[k] = v
+	}
+	c.Contexts = make(map[string]interface{}, len(e.Contexts))
+	for k, v := range e.Contexts {
+		c.Contexts[k] = v
+	}
+	return &c
+}
+
+// copyStacktrace returns a copy of the stack trace with its own frames.
+func copyStacktrace(st *sentry.Stacktrace) *sentry.Stacktrace {
+	if st == nil {
+		return nil
+	}
+	c := *st
+	c.Frames = append([]sentry.Frame(nil), st.Frames...)
+	return &c
+}
+
+// setRuntimeTags tags the event with the number of goroutines and the most
+// recent garbage collection pause, unless the tags have already been set.
+func setRuntimeTags(e *sentry.Event) {
+	// Unlike runtime.ReadMemStats, this doesn't stop the world.
+	var stats debug.GCStats
+	debug.ReadGCStats(&stats)
+	tags := map[string]string{
+		"goroutines": strconv.Itoa(runtime.NumGoroutine()),
+	}
+	if len(stats.Pause) > 0 {
+		tags["last_gc_pause_ns"] = strconv.FormatInt(int64(stats.Pause[0]), 10)
+	}
+	for k, v := range tags {
+		if _, ok := e.Tags[k]; !ok {
+			setTag(e, k, v)
+		}
+	}
+}
+
+// isForced returns whether the event has the Force attribute.
+func isForced(data []interface{}) bool {
+	for _, d := range data {
+		if _, ok := d.(force); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesErrorFilter returns whether any error in the chain of an ErrorArg
+// matches any of the error filters.
+func (c *capturer) matchesErrorFilter(data []interface{}) bool {
+	for _, d := range data {
+		arg, ok := d.(glog.ErrorArg)
+		if !ok || isNilError(arg.Error) {
+			continue
+		}
+		maxDepth := getMaxErrorDepth()
+		chain := []chainedError{{arg.Error, 0, ""}}
+		for len(chain) > 0 {
+			err, depth := chain[0].err, chain[0].depth
+			chain = chain[1:]
+			for _, match := range c.errorFilter {
+				if match(err) {
+					return true
+				}
+			}
+			if depth+1 < maxDepth {
+				chain = append(unwrap(err, depth+1), chain...)
+			}
+		}
+	}
+	return false
+}
+
+// dropped reports an event which was not sent to Sentry.
+func (c *capturer) dropped(e *sentry.Event, fingerprint []string, reason DropReason) {
+	c.counters.Dropped(reason)
+	if c.onDropped != nil {
+		c.onDropped(e, fingerprint, reason)
+	}
+}
+
+// minimalEvent returns a copy of the event with only its message, fingerprint
+// and the fields which identify where it came from.
+func minimalEvent(e *sentry.Event) *sentry.Event {
+	m := sentry.NewEvent()
+	m.EventID = e.EventID
+	m.Timestamp = e.Timestamp
+	m.Level = e.Level
+	m.Message = e.Message
+	m.Fingerprint = e.Fingerprint
+	m.Logger = e.Logger
+	m.ServerName = e.ServerName
+	return m
+}
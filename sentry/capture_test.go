@@ -0,0 +1,811 @@
+package sentry_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	sentrygo "github.com/getsentry/sentry-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yext/glog"
+	"github.com/yext/glog-contrib/sentry"
+)
+
+// repeatedEvents returns count identical glog events logged from the same call site.
+func repeatedEvents(count int, msg string) []glog.Event {
+	var events []glog.Event
+	for i := 0; i < count; i++ {
+		events = append(events, newErrorEvent(msg, glog.ErrorArg{Error: errors.New(msg)}))
+	}
+	return events
+}
+
+func TestDedupWindow(t *testing.T) {
+	events := captureEvents(append(repeatedEvents(3, "first message"), repeatedEvents(1, "second message")...),
+		sentry.WithDedupWindow(time.Minute))
+
+	assert.Len(t, events, 2, "repeats within the window are dropped")
+	assert.Equal(t, "first message", events[0].Message)
+	assert.Equal(t, "second message", events[1].Message)
+}
+
+func TestDedupWindowExpired(t *testing.T) {
+	events := captureEvents(repeatedEvents(2, "test message"), sentry.WithDedupWindow(time.Nanosecond))
+
+	assert.Len(t, events, 2, "events outside of the window are captured")
+}
+
+func TestDedupWindowTimesSeen(t *testing.T) {
+	events := repeatedEvents(4, "test message")
+	events = append(events[:3], newErrorEvent("wait"), events[3])
+
+	captured := captureEvents(events,
+		sentry.WithDedupWindow(50*time.Millisecond),
+		sentry.WithBeforeSend(func(e *sentrygo.Event) *sentrygo.Event {
+			// Wait for the window to expire before the last event.
+			if e.Message == "wait" {
+				time.Sleep(100 * time.Millisecond)
+			}
+			return e
+		}))
+
+	require.Len(t, captured, 3)
+	assert.NotContains(t, captured[0].Extra, "TimesSeen")
+	assert.Equal(t, 3, captured[2].Extra["TimesSeen"], "the repeats within the previous window are counted")
+}
+
+func TestDedupWindowTimesSeenAfterSweep(t *testing.T) {
+	events := repeatedEvents(4, "test message")
+	events = append(events[:3], newErrorEvent("wait"), newErrorEvent("other message"), events[3])
+
+	captured := captureEvents(events,
+		sentry.WithDedupWindow(100*time.Millisecond),
+		sentry.WithBeforeSend(func(e *sentrygo.Event) *sentrygo.Event {
+			// Wait for the window to expire, but not the one after it.
+			if e.Message == "wait" {
+				time.Sleep(150 * time.Millisecond)
+			}
+			return e
+		}))
+
+	require.Len(t, captured, 4)
+	assert.Equal(t, 3, captured[3].Extra["TimesSeen"], "the count survives the sweep triggered by another event")
+}
+
+func TestRepeatLevel(t *testing.T) {
+	events := captureEvents(repeatedEvents(3, "test message"),
+		sentry.WithDedupWindow(time.Minute), sentry.WithRepeatLevel(sentrygo.LevelWarning))
+
+	assert.Len(t, events, 3, "repeats are captured")
+	assert.Equal(t, sentrygo.LevelError, events[0].Level, "first occurrence is an error")
+	assert.Equal(t, sentrygo.LevelWarning, events[1].Level, "repeats are warnings")
+	assert.Equal(t, sentrygo.LevelWarning, events[2].Level, "repeats are warnings")
+}
+
+func TestDialer(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "sentry.sock")
+	listener, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	defer listener.Close()
+
+	received := make(chan string, 1)
+	go http.Serve(listener, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.URL.Path
+	}))
+
+	// The host in the DSN is unreachable, so events can only be
+	// delivered through the socket.
+	comm := make(chan glog.Event, 1)
+	comm <- newErrorEvent("test message")
+	close(comm)
+	sentry.CaptureErrors("example", []string{"http://public@sentry.invalid/1"}, sentrygo.ClientOptions{}, comm,
+		sentry.WithDialer(func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		}))
+
+	select {
+	case path := <-received:
+		assert.Equal(t, "/api/1/store/", path)
+	case <-time.After(time.Second):
+		t.Fatal("event was not sent through the socket")
+	}
+}
+
+func TestDialerProxy(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "sentry.sock")
+	listener, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	defer listener.Close()
+
+	received := make(chan string, 1)
+	go http.Serve(listener, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.RequestURI
+	}))
+
+	// Events are sent to the proxy through the dialer.
+	var dialed []string
+	comm := make(chan glog.Event, 1)
+	comm <- newErrorEvent("test message")
+	close(comm)
+	sentry.CaptureErrors("example", []string{"http://public@sentry.invalid/1"},
+		sentrygo.ClientOptions{HTTPProxy: "http://proxy.invalid:3128"}, comm,
+		sentry.WithDialer(func(ctx context.Context, _, addr string) (net.Conn, error) {
+			dialed = append(dialed, addr)
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		}))
+
+	select {
+	case uri := <-received:
+		assert.Equal(t, "http://sentry.invalid/api/1/store/", uri)
+		assert.Equal(t, []string{"proxy.invalid:3128"}, dialed)
+	case <-time.After(time.Second):
+		t.Fatal("event was not sent through the proxy")
+	}
+}
+
+func TestMinSendInterval(t *testing.T) {
+	transport := &transportMock{}
+	comm := make(chan glog.Event)
+	done := make(chan struct{})
+	go func() {
+		sentry.CaptureErrors("example", []string{""}, sentrygo.ClientOptions{Transport: transport}, comm,
+			sentry.WithMinSendInterval(20*time.Millisecond, 2))
+		close(done)
+	}()
+
+	for i := 0; i < 5; i++ {
+		comm <- newErrorEvent(fmt.Sprintf("message %d", i))
+	}
+	close(comm)
+	<-done
+
+	times := transport.SendTimes()
+	assert.GreaterOrEqual(t, len(times), 2, "buffered events are sent")
+	assert.Less(t, len(times), 5, "events exceeding the buffer are dropped")
+	for i := 1; i < len(times); i++ {
+		assert.GreaterOrEqual(t, times[i].Sub(times[i-1]), 20*time.Millisecond, "sends are spaced out")
+	}
+}
+
+// concurrencyTransport records the most events sent to it at the same time.
+type concurrencyTransport struct {
+	transportMock
+	current, max int32
+}
+
+func (t *concurrencyTransport) SendEvent(event *sentrygo.Event) {
+	n := atomic.AddInt32(&t.current, 1)
+	for {
+		max := atomic.LoadInt32(&t.max)
+		if n <= max || atomic.CompareAndSwapInt32(&t.max, max, n) {
+			break
+		}
+	}
+	time.Sleep(10 * time.Millisecond)
+	atomic.AddInt32(&t.current, -1)
+	t.transportMock.SendEvent(event)
+}
+
+func TestMaxInFlight(t *testing.T) {
+	dsns := []string{"https://key@sentry.example.com/1", "https://key@sentry.example.com/2", "https://key@sentry.example.com/3"}
+	var events []glog.Event
+	for i := 0; i < 5; i++ {
+		for _, dsn := range dsns {
+			events = append(events, newErrorEvent("test message", sentry.AltDsn(dsn)))
+		}
+	}
+
+	for _, limit := range []int{1, 2} {
+		transport := &concurrencyTransport{}
+		comm := make(chan glog.Event, len(events))
+		for _, e := range events {
+			comm <- e
+		}
+		close(comm)
+		err := sentry.CaptureErrorsE("example", dsns, sentrygo.ClientOptions{Transport: transport}, comm,
+			sentry.WithMinSendInterval(time.Millisecond, len(events)),
+			sentry.WithMaxInFlight(limit))
+
+		assert.NoError(t, err)
+		assert.Len(t, transport.Events(), len(events))
+		assert.LessOrEqual(t, atomic.LoadInt32(&transport.max), int32(limit), "sends are limited")
+	}
+}
+
+func TestOnDroppedSampled(t *testing.T) {
+	var reasons []sentry.DropReason
+	var fingerprints [][]string
+	events := captureEvents(repeatedEvents(2, "test message"),
+		sentry.WithSampleRate(0),
+		sentry.WithOnCaptured(func(e *sentrygo.Event, fingerprint []string) {
+			t.Error("no events are captured")
+		}),
+		sentry.WithOnDropped(func(e *sentrygo.Event, fingerprint []string, reason sentry.DropReason) {
+			reasons = append(reasons, reason)
+			fingerprints = append(fingerprints, fingerprint)
+		}))
+
+	assert.Empty(t, events)
+	assert.Equal(t, []sentry.DropReason{sentry.DropSampled, sentry.DropSampled}, reasons)
+	require.Len(t, fingerprints, 2)
+	assert.Equal(t, "test message", fingerprints[0][0], "fingerprint begins with the exception types")
+	assert.Equal(t, fingerprints[0], fingerprints[1], "repeats have the same fingerprint")
+}
+
+func TestForce(t *testing.T) {
+	var reasons []sentry.DropReason
+	events := captureEvents([]glog.Event{
+		newErrorEvent("sampled message"),
+		newErrorEvent("forced message", sentry.Force()),
+		newErrorEvent("forced message", sentry.Force()),
+	},
+		sentry.WithSampleRate(0),
+		sentry.WithDedupWindow(time.Minute),
+		sentry.WithOnDropped(func(e *sentrygo.Event, fingerprint []string, reason sentry.DropReason) {
+			reasons = append(reasons, reason)
+		}))
+
+	require.Len(t, events, 2, "forced events bypass sampling and dedup")
+	assert.Contains(t, events[0].Message, "forced message")
+	assert.Contains(t, events[1].Message, "forced message")
+	assert.Equal(t, []sentry.DropReason{sentry.DropSampled}, reasons)
+}
+
+func TestOnCaptured(t *testing.T) {
+	var captured []string
+	var reasons []sentry.DropReason
+	events := captureEvents(repeatedEvents(2, "test message"),
+		sentry.WithDedupWindow(time.Minute),
+		sentry.WithOnCaptured(func(e *sentrygo.Event, fingerprint []string) {
+			captured = append(captured, e.Message)
+		}),
+		sentry.WithOnDropped(func(e *sentrygo.Event, fingerprint []string, reason sentry.DropReason) {
+			reasons = append(reasons, reason)
+		}))
+
+	assert.Len(t, events, 1)
+	assert.Equal(t, []string{"test message"}, captured)
+	assert.Equal(t, []sentry.DropReason{sentry.DropDuplicate}, reasons)
+}
+
+func TestCaptureErrorsE(t *testing.T) {
+	transport := &transportMock{}
+	comm := make(chan glog.Event, 1)
+	comm <- newErrorEvent("test message")
+	close(comm)
+
+	err := sentry.CaptureErrorsE("example", []string{""}, sentrygo.ClientOptions{Transport: transport}, comm)
+	assert.NoError(t, err, "returns cleanly when the channel closes")
+	assert.Len(t, transport.Events(), 1)
+	assert.Equal(t, 1, transport.Flushes(), "events are flushed before returning")
+
+	assert.Error(t, sentry.CaptureErrorsE("example", nil, sentrygo.ClientOptions{}, comm), "no DSNs")
+	assert.Error(t, sentry.CaptureErrorsE("example", []string{"invalid"}, sentrygo.ClientOptions{}, comm), "invalid DSN")
+}
+
+func TestCaptureErrorsWithContext(t *testing.T) {
+	transport := &transportMock{}
+	comm := make(chan glog.Event, 2)
+	comm <- newErrorEvent("first message")
+	comm <- newErrorEvent("second message")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := sentry.CaptureErrorsWithContext(ctx, "example", []string{""}, sentrygo.ClientOptions{Transport: transport}, comm,
+		sentry.WithFlushTimeout(5*time.Second))
+	assert.NoError(t, err, "returns cleanly when the context is done")
+	assert.Len(t, transport.Events(), 2, "events waiting in the channel are captured")
+	assert.Equal(t, []time.Duration{5 * time.Second}, transport.FlushTimeouts(), "events are flushed with the timeout")
+}
+
+func TestMinSeverity(t *testing.T) {
+	var events []glog.Event
+	for _, severity := range []string{"INFO", "WARNING", "ERROR", "FATAL"} {
+		e := newErrorEvent(strings.ToLower(severity) + " message")
+		e.Severity = severity
+		events = append(events, e)
+	}
+
+	captured := captureEvents(events)
+	require.Len(t, captured, 2, "errors and fatal events are captured by default")
+	assert.Equal(t, sentrygo.LevelError, captured[0].Level)
+	assert.Equal(t, sentrygo.LevelFatal, captured[1].Level)
+
+	captured = captureEvents(events, sentry.WithMinSeverity("WARNING"))
+	require.Len(t, captured, 3)
+	assert.Equal(t, sentrygo.LevelWarning, captured[0].Level)
+	assert.Equal(t, "warning message", captured[0].Message)
+	assert.Empty(t, captured[0].Exception, "warnings are captured as messages")
+	assert.Equal(t, sentrygo.LevelError, captured[1].Level)
+	assert.NotEmpty(t, captured[1].Exception, "errors are captured as exceptions")
+
+	captured = captureEvents(events, sentry.WithMinSeverity("FATAL"))
+	require.Len(t, captured, 1)
+	assert.Equal(t, sentrygo.LevelFatal, captured[0].Level)
+
+	comm := make(chan glog.Event)
+	close(comm)
+	err := sentry.CaptureErrorsE("example", []string{""}, sentrygo.ClientOptions{Transport: &transportMock{}}, comm,
+		sentry.WithMinSeverity("WARN"))
+	assert.ErrorContains(t, err, `"WARN"`, "unknown severities are rejected")
+}
+
+func TestDedupWarnings(t *testing.T) {
+	var events []glog.Event
+	for _, msg := range []string{"cache miss", "slow query", "cache miss"} {
+		e := newErrorEvent(msg)
+		e.Severity = "WARNING"
+		events = append(events, e)
+	}
+
+	captured := captureEvents(events, sentry.WithMinSeverity("WARNING"), sentry.WithDedupWindow(time.Minute))
+	require.Len(t, captured, 2, "different warnings aren't duplicates")
+	assert.Equal(t, "cache miss", captured[0].Message)
+	assert.Equal(t, "slow query", captured[1].Message)
+}
+
+func TestFatalFlushed(t *testing.T) {
+	transport := &transportMock{}
+	comm := make(chan glog.Event, 1)
+	e := newErrorEvent("fatal message")
+	e.Severity = "FATAL"
+	comm <- e
+	close(comm)
+
+	err := sentry.CaptureErrorsE("example", []string{""}, sentrygo.ClientOptions{Transport: transport}, comm,
+		sentry.WithMinSendInterval(time.Minute, 1))
+	assert.NoError(t, err)
+	assert.Len(t, transport.Events(), 1)
+	assert.Equal(t, 2, transport.Flushes(), "fatal events are flushed immediately")
+}
+
+func TestRuntimeTags(t *testing.T) {
+	runtime.GC()
+	events := captureEvents([]glog.Event{
+		newErrorEvent("test message"),
+		newErrorEvent("test message", sentry.Tags(map[string]string{"goroutines": "custom"})),
+	}, sentry.WithRuntimeTags())
+
+	require.Len(t, events, 2)
+	goroutines, err := strconv.Atoi(events[0].Tags["goroutines"])
+	assert.NoError(t, err)
+	assert.Greater(t, goroutines, 0)
+	_, err = strconv.ParseInt(events[0].Tags["last_gc_pause_ns"], 10, 64)
+	assert.NoError(t, err)
+	assert.Equal(t, "custom", events[1].Tags["goroutines"], "event tags take precedence")
+
+	events = captureEvents([]glog.Event{newErrorEvent("test message")})
+	assert.NotContains(t, events[0].Tags, "goroutines")
+}
+
+func TestBeforeSend(t *testing.T) {
+	var reasons []sentry.DropReason
+	events := captureEvents([]glog.Event{
+		newErrorEvent("password=hunter2"),
+		newErrorEvent("drop me"),
+	},
+		sentry.WithBeforeSend(func(e *sentrygo.Event) *sentrygo.Event {
+			if strings.Contains(e.Message, "drop me") {
+				return nil
+			}
+			e.Message = strings.ReplaceAll(e.Message, "hunter2", "[Filtered]")
+			return e
+		}),
+		sentry.WithOnDropped(func(e *sentrygo.Event, fingerprint []string, reason sentry.DropReason) {
+			assert.NotNil(t, e)
+			reasons = append(reasons, reason)
+		}))
+
+	require.Len(t, events, 1)
+	assert.Equal(t, "password=[Filtered]", events[0].Message, "events can be modified")
+	assert.Equal(t, []sentry.DropReason{sentry.DropBeforeSend}, reasons, "events can be dropped")
+}
+
+func TestDetailOnce(t *testing.T) {
+	req := httptest.NewRequest("GET", "/path", nil)
+	var events []glog.Event
+	for i := 0; i < 2; i++ {
+		err := errors.New("test message")
+		events = append(events, newErrorEvent(err.Error(), glog.ErrorArg{Error: err}, req))
+	}
+	captured := captureEvents(events, sentry.WithDetailOnce(time.Minute))
+
+	require.Len(t, captured, 2, "repeats are still captured")
+	first, second := captured[0], captured[1]
+	assert.NotEmpty(t, first.Exception, "first event has full detail")
+	assert.NotNil(t, first.Request)
+	assert.NotEmpty(t, first.Fingerprint)
+
+	assert.Equal(t, first.Message, second.Message)
+	assert.Equal(t, first.Fingerprint, second.Fingerprint, "repeats are grouped with the first event")
+	assert.Empty(t, second.Exception, "repeats omit the stacktrace")
+	assert.Nil(t, second.Request)
+}
+
+func TestTagsFromEnv(t *testing.T) {
+	t.Setenv("SENTRY_TAG_region", "us")
+	t.Setenv("SENTRY_TAG_cluster", "east-1")
+	t.Setenv("SENTRY_TAG_route", "default")
+	t.Setenv("SENTRY_TAG_", "ignored")
+	t.Setenv("OTHER_region", "eu")
+
+	events := captureEvents([]glog.Event{
+		newErrorEvent("test message"),
+		newErrorEvent("test message", sentry.Route("/users/{id}")),
+	}, sentry.WithTagsFromEnv("SENTRY_TAG_"))
+
+	require.Len(t, events, 2)
+	assert.Equal(t, "us", events[0].Tags["region"])
+	assert.Equal(t, "east-1", events[0].Tags["cluster"])
+	assert.Equal(t, "default", events[0].Tags["route"])
+	assert.NotContains(t, events[0].Tags, "")
+	assert.Equal(t, "us", events[1].Tags["region"])
+	assert.Equal(t, "/users/{id}", events[1].Tags["route"], "event tags take precedence")
+}
+
+var errNotFound = errors.New("not found")
+
+type apiError struct {
+	code int
+}
+
+func (e *apiError) Error() string { return fmt.Sprintf("api error %d", e.code) }
+
+func TestOnlyIfErrorIs(t *testing.T) {
+	var reasons []sentry.DropReason
+	events := captureEvents([]glog.Event{
+		newErrorEvent("lookup failed", glog.ErrorArg{Error: fmt.Errorf("lookup failed: %w", errNotFound)}),
+		newErrorEvent("other failure", glog.ErrorArg{Error: errors.New("other failure")}),
+		newErrorEvent("no error"),
+		newErrorEvent("joined", glog.ErrorArg{Error: joinedErrors{errors.New("first"), errNotFound}}),
+	}, sentry.OnlyIfErrorIs(errNotFound), sentry.WithOnDropped(func(e *sentrygo.Event, fingerprint []string, reason sentry.DropReason) {
+		reasons = append(reasons, reason)
+	}))
+
+	require.Len(t, events, 2, "only errors wrapping the sentinel are captured")
+	assert.Contains(t, events[0].Message, "lookup failed")
+	assert.Contains(t, events[1].Message, "joined")
+	assert.Equal(t, []sentry.DropReason{sentry.DropFiltered, sentry.DropFiltered}, reasons)
+}
+
+func TestOnlyIfErrorAs(t *testing.T) {
+	events := captureEvents([]glog.Event{
+		newErrorEvent("request failed", glog.ErrorArg{Error: fmt.Errorf("request failed: %w", &apiError{503})}),
+		newErrorEvent("lookup failed", glog.ErrorArg{Error: fmt.Errorf("lookup failed: %w", errNotFound)}),
+	}, sentry.OnlyIfErrorAs(func(err error) bool {
+		_, ok := err.(*apiError)
+		return ok
+	}))
+
+	require.Len(t, events, 1)
+	assert.Contains(t, events[0].Message, "request failed")
+}
+
+// panicError is an error which panics when formatted.
+type panicError struct{}
+
+func (panicError) Error() string { panic("broken error") }
+
+func TestCapturePanicRecovered(t *testing.T) {
+	events := captureEvents([]glog.Event{
+		newErrorEvent("broken", glog.ErrorArg{Error: panicError{}}),
+		newErrorEvent("test message"),
+	})
+
+	require.Len(t, events, 1, "capture continues after a panic")
+	assert.Equal(t, "test message", events[0].Message)
+}
+
+func TestEnvironment(t *testing.T) {
+	t.Setenv("SENTRY_ENVIRONMENT", "")
+	events := captureEvents([]glog.Event{newErrorEvent("test message")})
+	require.Len(t, events, 1)
+	assert.Empty(t, events[0].Environment, "none if not configured")
+
+	t.Setenv("SENTRY_ENVIRONMENT", "staging")
+	events = captureEvents([]glog.Event{newErrorEvent("test message")})
+	require.Len(t, events, 1)
+	assert.Equal(t, "staging", events[0].Environment, "set by the environment variable")
+
+	events = captureEvents([]glog.Event{newErrorEvent("test message")},
+		sentry.WithBeforeSend(func(e *sentrygo.Event) *sentrygo.Event {
+			e.Environment = "canary"
+			return e
+		}))
+	require.Len(t, events, 1)
+	assert.Equal(t, "canary", events[0].Environment, "the environment of the event takes precedence")
+}
+
+func TestMaxEventsPerSec(t *testing.T) {
+	var reasons []sentry.DropReason
+	events := captureEvents(append(repeatedEvents(5, "first message"), newErrorEvent("forced", sentry.Force())),
+		sentry.WithMaxEventsPerSec(2),
+		sentry.WithOnDropped(func(e *sentrygo.Event, fingerprint []string, reason sentry.DropReason) {
+			reasons = append(reasons, reason)
+		}))
+
+	require.Len(t, events, 3, "events beyond the burst are dropped")
+	assert.Equal(t, []sentry.DropReason{sentry.DropRateLimited, sentry.DropRateLimited, sentry.DropRateLimited}, reasons)
+	assert.NotContains(t, events[1].Extra, "RateLimited")
+	assert.Equal(t, "forced", events[2].Message, "forced events are not limited")
+	assert.Equal(t, 3, events[2].Extra["RateLimited"], "dropped events are counted on the next one sent")
+}
+
+func TestStartupEvent(t *testing.T) {
+	events := captureEvents(nil)
+	assert.Empty(t, events, "disabled by default")
+
+	events = captureEvents([]glog.Event{newErrorEvent("test message")}, sentry.WithStartupEvent())
+	require.Len(t, events, 2)
+	assert.Equal(t, sentrygo.LevelInfo, events[0].Level)
+	assert.Equal(t, "Started capturing errors for example", events[0].Message)
+	assert.Equal(t, "true", events[0].Tags["startup"])
+	assert.Equal(t, "test message", events[1].Message)
+}
+
+func TestRegisterSeverity(t *testing.T) {
+	sentry.RegisterSeverity("ALERT", sentrygo.LevelError)
+	sentry.RegisterSeverity("NOTICE", sentrygo.LevelInfo)
+
+	var events []glog.Event
+	for _, severity := range []string{"ALERT", "NOTICE", "UNKNOWN"} {
+		e := newErrorEvent(strings.ToLower(severity) + " message")
+		e.Severity = severity
+		events = append(events, e)
+	}
+
+	captured := captureEvents(events)
+	require.Len(t, captured, 1, "only custom severities at the minimum level are captured")
+	assert.Equal(t, "alert message", captured[0].Message)
+	assert.Equal(t, sentrygo.LevelError, captured[0].Level)
+
+	captured = captureEvents(events, sentry.WithMinSeverity("NOTICE"))
+	require.Len(t, captured, 2, "the minimum severity may be custom")
+	assert.Equal(t, sentrygo.LevelInfo, captured[1].Level)
+}
+
+func TestCaptureErrorsWithContextRestart(t *testing.T) {
+	comm := make(chan glog.Event)
+	for _, msg := range []string{"first message", "second message"} {
+		transport := &transportMock{}
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error)
+		go func() {
+			done <- sentry.CaptureErrorsWithContext(ctx, "example", []string{""}, sentrygo.ClientOptions{Transport: transport}, comm)
+		}()
+
+		comm <- newErrorEvent(msg)
+		cancel()
+		select {
+		case err := <-done:
+			assert.NoError(t, err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("capturing did not stop")
+		}
+		if events := transport.Events(); assert.Len(t, events, 1) {
+			assert.Equal(t, msg, events[0].Message, "each pipeline captures its own events")
+		}
+	}
+}
+
+func TestCaptureErrorsWithContextRestartGoroutines(t *testing.T) {
+	server := &sentryServer{}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+	dsn := strings.Replace(ts.URL, "http://", "http://public@", 1) + "/1"
+
+	comm := make(chan glog.Event)
+	restart := func(msg string) {
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error)
+		go func() {
+			done <- sentry.CaptureErrorsWithContext(ctx, "example", []string{dsn}, sentrygo.ClientOptions{}, comm)
+		}()
+		comm <- newErrorEvent(msg)
+		cancel()
+		require.NoError(t, <-done)
+	}
+
+	restart("first message")
+	before := runtime.NumGoroutine()
+	for i := 0; i < 20; i++ {
+		restart("message")
+	}
+	// The connections to the server may take a moment to close.
+	assert.Eventually(t, func() bool { return runtime.NumGoroutine() <= before+2 }, 5*time.Second, 10*time.Millisecond,
+		"goroutines leaked by restarting: %d before, %d after", before, runtime.NumGoroutine())
+	assert.Len(t, server.Messages(), 21, "events are flushed before capturing stops")
+}
+
+func TestCaptureErrorsWithContextRateLimited(t *testing.T) {
+	var requests int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Header().Set("X-Sentry-Rate-Limits", "60:error:key")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer ts.Close()
+	dsn := strings.Replace(ts.URL, "http://", "http://public@", 1) + "/1"
+
+	comm := make(chan glog.Event, 3)
+	for i := 0; i < 3; i++ {
+		comm <- newErrorEvent("test message")
+	}
+	close(comm)
+	err := sentry.CaptureErrorsWithContext(context.Background(), "example", []string{dsn}, sentrygo.ClientOptions{}, comm)
+
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests), "no events are sent while rate limited")
+}
+
+func TestAltDsns(t *testing.T) {
+	dsns := []string{"https://key@sentry.example.com/1", "https://key@sentry.example.com/2"}
+	const unknownDsn = "https://key@sentry.example.com/3"
+
+	_, dsn := sentry.FromGlogEvent(newErrorEvent("test message", sentry.AltDsns(dsns[1], dsns[0])))
+	assert.Equal(t, dsns[1], dsn, "the first DSN is returned")
+
+	for _, tc := range []struct {
+		name string
+		data []interface{}
+		sent int
+	}{
+		{"each DSN", []interface{}{sentry.AltDsns(dsns...)}, 2},
+		{"several AltDsn", []interface{}{sentry.AltDsn(dsns[0]), sentry.AltDsn(dsns[1])}, 2},
+		{"unknown DSN falls back to the primary", []interface{}{sentry.AltDsns(dsns[1], unknownDsn)}, 2},
+		{"primary DSN is sent to once", []interface{}{sentry.AltDsns(dsns[0], unknownDsn)}, 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			transport := &transportMock{}
+			comm := make(chan glog.Event, 1)
+			comm <- newErrorEvent("test message", tc.data...)
+			close(comm)
+			err := sentry.CaptureErrorsE("example", dsns, sentrygo.ClientOptions{Transport: transport}, comm)
+
+			require.NoError(t, err)
+			events := transport.Events()
+			require.Len(t, events, tc.sent)
+			if len(events) == 2 {
+				assert.Equal(t, events[0].Message, events[1].Message)
+				assert.NotEqual(t, events[0].EventID, events[1].EventID, "each copy has its own ID")
+			}
+		})
+	}
+}
+
+func TestAltDsnsPaced(t *testing.T) {
+	dsns := []string{"https://key@sentry.example.com/1", "https://key@sentry.example.com/2"}
+	var events []glog.Event
+	for i := 0; i < 20; i++ {
+		events = append(events, newErrorEvent("test message", sentry.AltDsns(dsns...),
+			map[string]interface{}{"attempt": i}))
+	}
+
+	transport := &transportMock{}
+	comm := make(chan glog.Event, len(events))
+	for _, e := range events {
+		comm <- e
+	}
+	close(comm)
+	// Each hub modifies its copy of the event on the goroutine of its pacer,
+	// which mustn't race with copying the event for the next hub.
+	err := sentry.CaptureErrorsE("example", dsns, sentrygo.ClientOptions{
+		Transport: transport,
+		BeforeSend: func(e *sentrygo.Event, hint *sentrygo.EventHint) *sentrygo.Event {
+			e.Tags["paced"] = "true"
+			e.Extra["paced"] = true
+			e.Contexts["paced"] = map[string]interface{}{"paced": true}
+			e.Breadcrumbs = append(e.Breadcrumbs, &sentrygo.Breadcrumb{Message: "paced"})
+			return e
+		},
+	}, comm, sentry.WithMinSendInterval(time.Microsecond, len(events)))
+
+	require.NoError(t, err)
+	sent := transport.Events()
+	require.Len(t, sent, 2*len(events))
+	for _, e := range sent {
+		assert.Len(t, e.Breadcrumbs, 1, "each copy is modified once")
+	}
+}
+
+// blockingTransport blocks sending events until it is released.
+type blockingTransport struct {
+	transportMock
+	started chan struct{}
+	release chan struct{}
+}
+
+func (t *blockingTransport) SendEvent(event *sentrygo.Event) {
+	select {
+	case t.started <- struct{}{}:
+	default:
+	}
+	<-t.release
+	t.transportMock.SendEvent(event)
+}
+
+func TestWorkers(t *testing.T) {
+	transport := &blockingTransport{started: make(chan struct{}, 1), release: make(chan struct{})}
+	comm := make(chan glog.Event)
+	var mu sync.Mutex
+	var reasons []sentry.DropReason
+	done := make(chan error)
+	go func() {
+		done <- sentry.CaptureErrorsE("example", []string{""}, sentrygo.ClientOptions{Transport: transport}, comm,
+			sentry.WithWorkers(1, 2),
+			sentry.WithOnDropped(func(e *sentrygo.Event, fingerprint []string, reason sentry.DropReason) {
+				mu.Lock()
+				defer mu.Unlock()
+				reasons = append(reasons, reason)
+			}))
+	}()
+
+	events := repeatedEvents(10, "test message")
+	comm <- events[0]
+	<-transport.started
+	timeout := time.After(5 * time.Second)
+	for _, e := range events[1:] {
+		select {
+		case comm <- e:
+		case <-timeout:
+			t.Fatal("logging is blocked by the send")
+		}
+	}
+	close(comm)
+	// Wait for the last event to be offered, before the worker is freed.
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(reasons) == 7
+	}, 5*time.Second, time.Millisecond, "events beyond the queue are dropped")
+	close(transport.release)
+	require.NoError(t, <-done)
+
+	assert.Len(t, transport.Events(), 3, "queued events are sent")
+	for _, reason := range reasons {
+		assert.Equal(t, sentry.DropQueueFull, reason)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	metrics := &countingMetrics{}
+	events := append(repeatedEvents(3, "test message"), glog.Event{Severity: "INFO", Message: []byte("ignored")})
+	captureEvents(events, sentry.WithMetrics(metrics), sentry.WithDedupWindow(time.Minute))
+
+	assert.Equal(t, 3, metrics.received, "events below the minimum severity aren't received")
+	assert.Equal(t, 1, metrics.sent)
+	assert.Equal(t, map[sentry.DropReason]int{sentry.DropDuplicate: 2}, metrics.dropped)
+	assert.Equal(t, 0, metrics.failed)
+}
+
+func TestMetricsClientDropped(t *testing.T) {
+	metrics := &countingMetrics{}
+	transport := &transportMock{}
+	comm := make(chan glog.Event, 1)
+	comm <- newErrorEvent("test message")
+	close(comm)
+	err := sentry.CaptureErrorsE("example", []string{""}, sentrygo.ClientOptions{
+		Transport:  transport,
+		BeforeSend: func(e *sentrygo.Event, hint *sentrygo.EventHint) *sentrygo.Event { return nil },
+	}, comm, sentry.WithMetrics(metrics))
+
+	require.NoError(t, err)
+	assert.Empty(t, transport.Events())
+	assert.Equal(t, 1, metrics.received)
+	assert.Equal(t, 0, metrics.sent)
+	assert.Equal(t, map[sentry.DropReason]int{sentry.DropClient: 1}, metrics.dropped)
+}
@@ -0,0 +1,91 @@
+package sentry
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/yext/glog"
+)
+
+// The name of the file attached to events with the chain of their errors.
+const errorChainFilename = "error_chain.json.gz"
+
+// The largest compressed error chain which is attached to an event. Larger
+// chains are dropped rather than truncated.
+const maxErrorChainSize = 1 << 20
+
+// errorChainNode describes an error unwrapped from an event, in the order
+// they were traversed.
+type errorChainNode struct {
+	Depth   int    `json:"depth"`
+	Type    string `json:"type"`
+	Message string `json:"message"`
+	// Via is the method used to unwrap the error from its parent, if any.
+	Via string `json:"via,omitempty"`
+}
+
+// errorChain returns the errors unwrapped from each ErrorArg in the data, in
+// the order they are converted to exceptions, with their full messages.
+func errorChain(data []interface{}) []errorChainNode {
+	var nodes []errorChainNode
+	for _, d := range data {
+		arg, ok := d.(glog.ErrorArg)
+		if !ok || isNilError(arg.Error) {
+			continue
+		}
+		maxDepth := getMaxErrorDepth()
+		chain := []chainedError{{arg.Error, 0, ""}}
+		for len(chain) > 0 {
+			c := chain[0]
+			chain = chain[1:]
+			nodes = append(nodes, errorChainNode{
+				Depth:   c.depth,
+				Type:    fmt.Sprintf("%T", c.err),
+				Message: c.err.Error(),
+				Via:     c.via,
+			})
+			if c.depth+1 < maxDepth {
+				chain = append(unwrap(c.err, c.depth+1), chain...)
+			}
+		}
+	}
+	return nodes
+}
+
+// compressErrorChain returns the chain encoded as gzipped JSON, or nil if the
+// chain is empty or too large to attach.
+func compressErrorChain(nodes []errorChainNode) ([]byte, error) {
+	if len(nodes) == 0 {
+		return nil, nil
+	}
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(w).Encode(nodes); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	if buf.Len() > maxErrorChainSize {
+		return nil, fmt.Errorf("compressed error chain of %d errors is %d bytes, more than %d",
+			len(nodes), buf.Len(), maxErrorChainSize)
+	}
+	return buf.Bytes(), nil
+}
+
+// attachErrorChain sends the compressed error chain as an attachment to the
+// event, which was captured with the given ID by a client with the options.
+func (c *capturer) attachErrorChain(opts sentry.ClientOptions, id sentry.EventID, chain []byte) {
+	c.attachments.Add(1)
+	go func() {
+		defer c.attachments.Done()
+		if err := sendAttachment(opts, id, errorChainFilename, "application/gzip", chain); err != nil {
+			// Don't use glog, or we'll just end up in an infinite loop
+			log.Printf("Failed to attach error chain to Sentry event %s: %v", id, err)
+		}
+	}()
+}
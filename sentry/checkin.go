@@ -0,0 +1,43 @@
+package sentry
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+)
+
+// CheckInStatus is the status of a cron monitor check-in.
+type CheckInStatus string
+
+// The statuses of a job reported by CheckIn.
+const (
+	CheckInInProgress CheckInStatus = "in_progress"
+	CheckInOK         CheckInStatus = "ok"
+	CheckInError      CheckInStatus = "error"
+)
+
+// CheckIn reports the status of a batch job to the Sentry cron monitor with
+// the given slug, using the primary DSN passed to CaptureErrors. A job would
+// typically check in with CheckInInProgress when it starts, followed by either
+// CheckInOK or CheckInError when it finishes.
+//
+// It does nothing if CaptureErrors has not been started with a DSN.
+func CheckIn(monitorSlug string, status CheckInStatus) error {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return err
+	}
+
+	opts := primaryOptions()
+	payload, err := json.Marshal(struct {
+		CheckInID   string        `json:"check_in_id"`
+		MonitorSlug string        `json:"monitor_slug"`
+		Status      CheckInStatus `json:"status"`
+		Release     string        `json:"release,omitempty"`
+		Environment string        `json:"environment,omitempty"`
+	}{hex.EncodeToString(id), monitorSlug, status, opts.Release, opts.Environment})
+	if err != nil {
+		return err
+	}
+	return sendEnvelope("check_in", payload)
+}
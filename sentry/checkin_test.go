@@ -0,0 +1,68 @@
+package sentry_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	sentrygo "github.com/getsentry/sentry-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yext/glog"
+	"github.com/yext/glog-contrib/sentry"
+)
+
+func TestCheckIn(t *testing.T) {
+	type request struct {
+		path, auth string
+		lines      []string
+	}
+	requests := make(chan request, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var lines []string
+		for scanner := bufio.NewScanner(r.Body); scanner.Scan(); {
+			lines = append(lines, scanner.Text())
+		}
+		requests <- request{r.URL.Path, r.Header.Get("X-Sentry-Auth"), lines}
+	}))
+	defer server.Close()
+
+	comm := make(chan glog.Event)
+	done := make(chan struct{})
+	dsn := strings.Replace(server.URL, "://", "://public@", 1) + "/1"
+	go func() {
+		sentry.CaptureErrors("example", []string{dsn}, sentrygo.ClientOptions{Environment: "prod"}, comm)
+		close(done)
+	}()
+	defer func() {
+		close(comm)
+		<-done
+	}()
+	comm <- glog.Event{Severity: "INFO"} // wait for the clients to be configured
+
+	require.NoError(t, sentry.CheckIn("nightly-job", sentry.CheckInOK))
+	r := <-requests
+	assert.Equal(t, "/api/1/envelope/", r.path)
+	assert.Contains(t, r.auth, "sentry_key=public")
+	require.Len(t, r.lines, 3, "envelope header, item header and payload")
+
+	var header, item, payload map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(r.lines[0]), &header))
+	require.NoError(t, json.Unmarshal([]byte(r.lines[1]), &item))
+	require.NoError(t, json.Unmarshal([]byte(r.lines[2]), &payload))
+	assert.Equal(t, dsn, header["dsn"])
+	assert.Equal(t, "check_in", item["type"])
+	assert.EqualValues(t, len(r.lines[2]), item["length"])
+	assert.Equal(t, "nightly-job", payload["monitor_slug"])
+	assert.Equal(t, "ok", payload["status"])
+	assert.Equal(t, "prod", payload["environment"])
+	assert.Len(t, payload["check_in_id"], 32)
+}
+
+func TestCheckInWithoutDsn(t *testing.T) {
+	captureEvents(nil)
+	assert.NoError(t, sentry.CheckIn("nightly-job", sentry.CheckInInProgress), "no-op without a DSN")
+}
@@ -0,0 +1,28 @@
+package sentry
+
+import (
+	"context"
+
+	"github.com/getsentry/sentry-go"
+)
+
+// As events are sent to this package over a channel, they are captured on
+// another goroutine than the one which logged them. So rather than being
+// goroutine-local, any state for the request is carried by a context, which
+// is passed to glog using the Context attribute.
+
+type scopeContextKey struct{}
+
+// WithRequestScope returns a copy of the context carrying the scope, which is
+// applied to any events logged with the context passed as a Context attribute.
+// This allows tags or user data set once for a request to apply to every error
+// logged while handling it.
+func WithRequestScope(ctx context.Context, scope *sentry.Scope) context.Context {
+	return context.WithValue(ctx, scopeContextKey{}, scope)
+}
+
+// ScopeFromContext returns the scope carried by the context, if any.
+func ScopeFromContext(ctx context.Context) *sentry.Scope {
+	scope, _ := ctx.Value(scopeContextKey{}).(*sentry.Scope)
+	return scope
+}
@@ -0,0 +1,104 @@
+package sentry
+
+import (
+	"sync"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+)
+
+// deduper tracks when events were first seen, in order to
+// identify repeats within a time window.
+type deduper struct {
+	window time.Duration
+
+	mu        sync.Mutex
+	seen      map[string]*dedupEntry
+	lastSweep time.Time
+}
+
+// dedupEntry is the window of an event, and the number of times it was seen
+// within it.
+type dedupEntry struct {
+	first time.Time
+	count int
+}
+
+func newDeduper(window time.Duration) *deduper {
+	return &deduper{
+		window:    window,
+		seen:      map[string]*dedupEntry{},
+		lastSweep: time.Now(),
+	}
+}
+
+// repeat reports whether an event with the given key was already seen within
+// the window. If not, the window for the key starts now.
+func (d *deduper) repeat(key string) bool {
+	repeat, _ := d.observe(key)
+	return repeat
+}
+
+// observe is like repeat, but if the event is not a repeat also returns the
+// number of times it was seen within its previous window, or zero if it
+// wasn't seen.
+func (d *deduper) observe(key string) (repeat bool, timesSeen int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	entry, ok := d.seen[key]
+	if ok && now.Sub(entry.first) < d.window {
+		entry.count++
+		return true, 0
+	}
+	if ok {
+		timesSeen = entry.count
+	}
+	d.seen[key] = &dedupEntry{first: now, count: 1}
+
+	// Periodically remove expired keys so that unique events
+	// don't grow the map indefinitely. Keys which were repeated are kept
+	// for one more window, so that their count can still be reported if
+	// they recur, and are then removed with a summary of their repeats.
+	if now.Sub(d.lastSweep) >= d.window {
+		var evicted, repeats int
+		for k, entry := range d.seen {
+			age := now.Sub(entry.first)
+			if age >= d.window && entry.count == 1 {
+				delete(d.seen, k)
+			} else if age >= 2*d.window {
+				evicted++
+				repeats += entry.count - 1
+				delete(d.seen, k)
+			}
+		}
+		if evicted > 0 {
+			sentry.Logger.Printf("Dropped %d repeats of %d events which didn't recur", repeats, evicted)
+		}
+		d.lastSweep = now
+	}
+	return false, timesSeen
+}
+
+// eventFingerprint returns the fingerprint used to identify repeats of an
+// event: its fingerprint if one was set, or otherwise the types of its
+// exceptions and the in-app frames of the top exception. Events without
+// exceptions (such as warnings) are identified by their message.
+func eventFingerprint(e *sentry.Event) []string {
+	if len(e.Fingerprint) > 0 {
+		return e.Fingerprint
+	}
+	if len(e.Exception) == 0 {
+		return buildMessageFingerprint(e.Message)
+	}
+
+	var parts []string
+	for _, ex := range e.Exception {
+		parts = append(parts, ex.Type)
+	}
+	if len(e.Exception) > 0 && e.Exception[0].Stacktrace != nil {
+		parts = append(parts, buildFingerprint(e.Exception)...)
+	}
+	return parts
+}
@@ -0,0 +1,31 @@
+package sentry
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeduperSweepsExpiredKeys(t *testing.T) {
+	const window = 50 * time.Millisecond
+	d := newDeduper(window)
+	for i := 0; i < 3; i++ {
+		d.observe("repeated")
+	}
+	d.observe("unique")
+	assert.Len(t, d.seen, 2)
+
+	// After one window, only the key which was repeated is kept, so that its
+	// count can be reported if it recurs.
+	time.Sleep(window + 10*time.Millisecond)
+	d.observe("other")
+	assert.Contains(t, d.seen, "repeated")
+	assert.NotContains(t, d.seen, "unique")
+
+	// Once its next window has closed too, it is removed.
+	time.Sleep(2*window + 10*time.Millisecond)
+	d.observe("other")
+	assert.Len(t, d.seen, 1, "only the key just observed is kept")
+	assert.Contains(t, d.seen, "other")
+}
@@ -0,0 +1,63 @@
+package sentry
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/getsentry/sentry-go"
+)
+
+// DiffEvents compares the fields of two events which Sentry uses to group
+// them into issues: the fingerprint, the message, and the type, value and
+// top stack frame of each exception. It returns a description of each
+// differing field, one per line, or an empty string if the events would
+// be grouped using identical data. If either event is nil, it returns a
+// line saying which, unless both are.
+//
+// This is intended as a debugging aid for determining why two errors
+// were tracked as separate issues.
+func DiffEvents(a, b *sentry.Event) string {
+	switch {
+	case a == nil && b == nil:
+		return ""
+	case a == nil:
+		return "event: first is nil"
+	case b == nil:
+		return "event: second is nil"
+	}
+
+	var d eventDiff
+
+	d.compare("fingerprint", fmt.Sprintf("%q", a.Fingerprint), fmt.Sprintf("%q", b.Fingerprint))
+	d.compare("message", a.Message, b.Message)
+	d.compare("exception count", len(a.Exception), len(b.Exception))
+
+	for i := 0; i < len(a.Exception) && i < len(b.Exception); i++ {
+		exA, exB := a.Exception[i], b.Exception[i]
+		d.compare(fmt.Sprintf("exception[%d].type", i), exA.Type, exB.Type)
+		d.compare(fmt.Sprintf("exception[%d].value", i), exA.Value, exB.Value)
+		d.compare(fmt.Sprintf("exception[%d].top frame", i), topFrame(exA.Stacktrace), topFrame(exB.Stacktrace))
+	}
+
+	return strings.Join(d.lines, "\n")
+}
+
+type eventDiff struct {
+	lines []string
+}
+
+func (d *eventDiff) compare(field string, a, b interface{}) {
+	if a != b {
+		d.lines = append(d.lines, fmt.Sprintf("%s: %q != %q", field, fmt.Sprint(a), fmt.Sprint(b)))
+	}
+}
+
+// topFrame describes the most recent frame of the stacktrace, which
+// appears last in Sentry's ordering.
+func topFrame(s *sentry.Stacktrace) string {
+	if s == nil || len(s.Frames) == 0 {
+		return ""
+	}
+	f := s.Frames[len(s.Frames)-1]
+	return fmt.Sprintf("%s in %s at line %d", f.Filename, f.Function, f.Lineno)
+}
@@ -0,0 +1,61 @@
+package sentry_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	sentrygo "github.com/getsentry/sentry-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/yext/glog"
+	"github.com/yext/glog-contrib/sentry"
+)
+
+func TestDiffEventsGrouped(t *testing.T) {
+	var events []*sentrygo.Event
+	for i := 0; i < 2; i++ {
+		err := errors.New("lookup failed")
+		e, _ := sentry.FromGlogEvent(newErrorEvent(err.Error(), glog.ErrorArg{Error: err}))
+		events = append(events, e)
+	}
+
+	assert.Empty(t, sentry.DiffEvents(events[0], events[1]), "identical errors group together")
+}
+
+func TestDiffEventsDifferentCallSites(t *testing.T) {
+	err := errors.New("lookup failed")
+	a, _ := sentry.FromGlogEvent(newErrorEvent(err.Error(), glog.ErrorArg{Error: err}))
+	b, _ := sentry.FromGlogEvent(newErrorEvent(err.Error(), glog.ErrorArg{Error: err}))
+
+	diff := sentry.DiffEvents(a, b)
+	assert.True(t, strings.HasPrefix(diff, "exception[0].value: "), "different call sites are reported: "+diff)
+	assert.Contains(t, diff, "exception[0].top frame: ", "different call sites are reported: "+diff)
+	assert.NotContains(t, diff, "exception[1]", "error exceptions are identical")
+}
+
+func TestDiffEventsNotGrouped(t *testing.T) {
+	var events []*sentrygo.Event
+	var line int
+	for i, data := range [][]interface{}{
+		{glog.ErrorArg{Error: errors.New("lookup failed: id 1")}},
+		{glog.ErrorArg{Error: errors.New("lookup failed: id 2")}, sentry.Fingerprint("lookup")},
+	} {
+		line = 1 + currentLine() // this should point to the next line
+		e, _ := sentry.FromGlogEvent(newErrorEvent(fmt.Sprintf("lookup failed: id %d", i+1), data...))
+		events = append(events, e)
+	}
+
+	assert.Equal(t, fmt.Sprintf(`fingerprint: "[]" != "[\"lookup\"]"
+message: "lookup failed: id 1" != "lookup failed: id 2"
+exception[0].value: "id 1 (TestDiffEventsNotGrouped:%d)" != "id 2 (TestDiffEventsNotGrouped:%d)"
+exception[1].value: "id 1" != "id 2"`, line, line), sentry.DiffEvents(events[0], events[1]))
+}
+
+func TestDiffEventsNil(t *testing.T) {
+	e, _ := sentry.FromGlogEvent(newErrorEvent("lookup failed"))
+
+	assert.Equal(t, "event: first is nil", sentry.DiffEvents(nil, e))
+	assert.Equal(t, "event: second is nil", sentry.DiffEvents(e, nil))
+	assert.Empty(t, sentry.DiffEvents(nil, nil))
+}
@@ -0,0 +1,122 @@
+package sentry
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+)
+
+// Items such as check-ins and metrics are not supported by the Sentry client,
+// so they are sent in envelopes directly to the primary DSN.
+
+var (
+	primaryOptsMu sync.RWMutex
+	primaryOpts   sentry.ClientOptions
+)
+
+// setPrimaryOptions sets the options of the client for the primary DSN
+// passed to CaptureErrors, which is used to send envelopes.
+func setPrimaryOptions(opts sentry.ClientOptions) {
+	primaryOptsMu.Lock()
+	defer primaryOptsMu.Unlock()
+	primaryOpts = opts
+}
+
+func primaryOptions() sentry.ClientOptions {
+	primaryOptsMu.RLock()
+	defer primaryOptsMu.RUnlock()
+	return primaryOpts
+}
+
+// sendEnvelope sends an envelope containing a single item to the primary DSN.
+// It does nothing if CaptureErrors has not been started with a DSN.
+func sendEnvelope(itemType string, payload []byte) error {
+	return sendEnvelopeItem(primaryOptions(), nil, map[string]interface{}{"type": itemType}, payload)
+}
+
+// sendAttachment sends an envelope containing a file to attach to the event
+// with the given ID, to the DSN of the client options. It does nothing if the
+// options have no DSN.
+func sendAttachment(opts sentry.ClientOptions, eventID sentry.EventID, filename, contentType string, payload []byte) error {
+	return sendEnvelopeItem(opts, map[string]interface{}{"event_id": eventID}, map[string]interface{}{
+		"type":         "attachment",
+		"filename":     filename,
+		"content_type": contentType,
+	}, payload)
+}
+
+// sendEnvelopeItem sends an envelope containing a single item to the DSN of
+// the client options, with the given envelope and item headers in addition to
+// those which are required. It does nothing if the options have no DSN.
+func sendEnvelopeItem(opts sentry.ClientOptions, envelopeHeader, itemHeader map[string]interface{}, payload []byte) error {
+	if opts.Dsn == "" {
+		return nil
+	}
+	dsn, err := sentry.NewDsn(opts.Dsn)
+	if err != nil {
+		return err
+	}
+
+	h := map[string]interface{}{
+		"sent_at": time.Now().UTC().Format(time.RFC3339Nano),
+		"dsn":     dsn.String(),
+	}
+	for k, v := range envelopeHeader {
+		h[k] = v
+	}
+	header, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+	itemHeader["length"] = len(payload)
+	item, err := json.Marshal(itemHeader)
+	if err != nil {
+		return err
+	}
+	var body bytes.Buffer
+	body.Write(header)
+	body.WriteByte('\n')
+	body.Write(item)
+	body.WriteByte('\n')
+	body.Write(payload)
+	body.WriteByte('\n')
+
+	req, err := http.NewRequest(http.MethodPost, dsn.EnvelopeAPIURL().String(), &body)
+	if err != nil {
+		return err
+	}
+	for k, v := range dsn.RequestHeaders() {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Content-Type", "application/x-sentry-envelope")
+
+	client := opts.HTTPClient
+	if client == nil {
+		client = &http.Client{Transport: opts.HTTPTransport, Timeout: 30 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return &statusError{item: fmt.Sprint(itemHeader["type"]), code: resp.StatusCode, status: resp.Status}
+	}
+	return nil
+}
+
+// statusError is the error status with which Sentry rejected an envelope.
+type statusError struct {
+	item   string
+	code   int
+	status string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("sending %s failed: %s", e.item, e.status)
+}
@@ -0,0 +1,23 @@
+package sentry
+
+import (
+	"reflect"
+)
+
+// errorFields returns the structured fields carried by an error which
+// implements a Fields() method returning a map with string keys, or nil
+// if it does not. The method is called by reflection, so that methods
+// returning named map types (such as logrus.Fields) are supported.
+func errorFields(err error) map[string]interface{} {
+	fields, ok := callMethod(reflect.ValueOf(err), "Fields")
+	if !ok || fields.Kind() != reflect.Map || fields.Type().Key().Kind() != reflect.String || fields.Len() == 0 {
+		return nil
+	}
+
+	r := make(map[string]interface{}, fields.Len())
+	iter := fields.MapRange()
+	for iter.Next() {
+		r[iter.Key().String()] = iter.Value().Interface()
+	}
+	return r
+}
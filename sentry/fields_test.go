@@ -0,0 +1,44 @@
+package sentry_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yext/glog"
+	"github.com/yext/glog-contrib/sentry"
+)
+
+type Fields map[string]interface{}
+
+type fieldedError struct {
+	err    error
+	fields Fields
+}
+
+func (e *fieldedError) Error() string  { return "fielded: " + e.err.Error() }
+func (e *fieldedError) Unwrap() error  { return e.err }
+func (e *fieldedError) Fields() Fields { return e.fields }
+
+func TestErrorFields(t *testing.T) {
+	err := fmt.Errorf("outer: %w", &fieldedError{
+		err:    fmt.Errorf("inner"),
+		fields: Fields{"account": 1234, "region": "us"},
+	})
+	e, _ := sentry.FromGlogEvent(newErrorEvent(err.Error(), glog.ErrorArg{Error: err}))
+
+	assert.Equal(t, map[string]interface{}{"account": 1234, "region": "us"}, e.Extra["ErrorFields.1"],
+		"fields are keyed by the position of the error in the chain")
+	assert.NotContains(t, e.Extra, "ErrorFields.0", "errors without fields are skipped")
+	assert.NotContains(t, e.Extra, "ErrorFields.2", "errors without fields are skipped")
+}
+
+func TestErrorFieldsOfEachError(t *testing.T) {
+	first := fmt.Errorf("first: %w", &fieldedError{err: fmt.Errorf("inner"), fields: Fields{"account": 1234}})
+	second := &fieldedError{err: fmt.Errorf("other"), fields: Fields{"account": 5678}}
+	e, _ := sentry.FromGlogEvent(newErrorEvent("two errors", glog.ErrorArg{Error: first}, glog.ErrorArg{Error: second}))
+
+	assert.Equal(t, map[string]interface{}{"account": 1234}, e.Extra["ErrorFields.1"])
+	assert.Equal(t, map[string]interface{}{"account": 5678}, e.Extra["ErrorFields.3"],
+		"the positions continue across the errors logged, so that neither overwrites the other")
+}
@@ -1,13 +1,15 @@
 package sentry
 
 import (
+	"log"
 	"regexp"
 	"strings"
+	"sync"
 
+	"github.com/yext/glog-contrib/internal/convert"
 	"github.com/yext/glog-contrib/stacktrace"
 
 	"github.com/getsentry/sentry-go"
-	"golang.org/x/xerrors"
 )
 
 var formatStringRe *regexp.Regexp
@@ -16,6 +18,34 @@ func init() {
 	formatStringRe = regexp.MustCompile(`%#?\+?\w+ ?`)
 }
 
+var (
+	messagePrefixParserMu sync.RWMutex
+	messagePrefixParser   func(msg []byte) string
+)
+
+// SetMessagePrefixParser sets the function which removes the header that glog
+// prepends to each message, returning the message itself, for sites which use
+// a custom glog format. By default, the header is parsed as glog formats it
+// (ending with the first "] "), and messages without one are left as is.
+// Passing nil restores the default.
+func SetMessagePrefixParser(parse func(msg []byte) string) {
+	messagePrefixParserMu.Lock()
+	defer messagePrefixParserMu.Unlock()
+	messagePrefixParser = parse
+}
+
+// removeGlogPrefix returns the message logged by glog, without its header.
+func removeGlogPrefix(msg []byte) string {
+	messagePrefixParserMu.RLock()
+	parse := messagePrefixParser
+	messagePrefixParserMu.RUnlock()
+	if parse != nil {
+		return parse(msg)
+	}
+	_, message := stacktrace.ParseGlogPrefix(msg)
+	return message
+}
+
 // headline returns a good Headline for this error.
 // Ideally, it returns a succinct summary that best conveys the error.
 // Most likely, that's something close to the root cause, but that may
@@ -25,12 +55,16 @@ func headline(err error) string {
 	// This provides context on the error, since returned errors are often constants.
 	var prev error
 	for {
-		wrapper, ok := err.(xerrors.Wrapper)
+		next, ok := convert.WrappedError(err)
 		if !ok {
 			break
 		}
+		if isNilError(next) {
+			prev = err
+			break
+		}
 		prev = err
-		err = wrapper.Unwrap()
+		err = next
 	}
 	if prev != nil {
 		return prev.Error()
@@ -38,24 +72,29 @@ func headline(err error) string {
 	return err.Error()
 }
 
-// removeGlogPrefixFromMessage removes the glog date/level from the
-// raw byte string returned from glogEvent.Message
-func removeGlogPrefixFromMessage(msg []byte) string {
-	message := string(msg)
-	if square := strings.Index(message, "] "); square != -1 {
-		message = message[square+2:]
-	}
-
-	return message
-}
-
 // splitMessage cleans up a message displayed as the top-line
 // Sentry error by splitting at the first newline, and checking
 // for presence of a colon (:). It returns a string for anything
 // present before a colon, as well as a string for anything after it.
+// URLs and times (which are likely to be unique) are kept out of the type,
+// which ends before the first of them, and a message beginning with a
+// timestamp or URL is split after it instead.
 func splitMessage(msg string) (string, string) {
 	firstLine := strings.Split(strings.TrimSpace(msg), "\n")[0]
 	parts := strings.SplitN(firstLine, ": ", 2)
+	switch start, end := volatileToken(parts[0]); {
+	case start < 0:
+	case start == 0:
+		rest := strings.TrimLeft(firstLine[end:], ":,;-]) ")
+		if rest != "" {
+			return splitMessage(rest)
+		}
+	default:
+		msgType := strings.TrimRight(parts[0][:start], " \t\"'`([<=")
+		if msgType != "" {
+			return msgType, strings.TrimSpace(firstLine[len(msgType):])
+		}
+	}
 	if len(parts) == 2 {
 		return parts[0], parts[1]
 	} else {
@@ -63,6 +102,25 @@ func splitMessage(msg string) (string, string) {
 	}
 }
 
+// Matches URLs (anything with a scheme), and times of day with an optional
+// date, such as "12:00", "12:00:05.123" or "2006-01-02 15:04:05". Times must
+// stand alone, so that addresses such as "10.0.0.1:80" aren't matched.
+var volatileRe = regexp.MustCompile(`([a-zA-Z][a-zA-Z0-9+.-]*://\S*)|(?:^|[^\w.:/-])((?:\d{4}[-/]\d{2}[-/]\d{2}[ T])?\d{1,2}:[0-5]\d(?::[0-5]\d(?:\.\d+)?)?)(?:$|[^\w:])`)
+
+// volatileToken returns the bounds of the first URL or time in the message,
+// or -1 if there are none.
+func volatileToken(msg string) (int, int) {
+	m := volatileRe.FindStringSubmatchIndex(msg)
+	switch {
+	case m == nil:
+		return -1, -1
+	case m[2] >= 0:
+		return m[2], m[3]
+	default:
+		return m[4], m[5]
+	}
+}
+
 // addExceptionSource adds the source of the exception, if present,
 // to the string value provided. If the string value is non-empty,
 // it places the source in parentheses as long as the source exists.
@@ -105,8 +163,51 @@ func prependMessage(possiblePrefix, fullMsg string) string {
 	}
 }
 
+var (
+	severityLevelsMu sync.RWMutex
+	// The Sentry level of each glog severity.
+	severityLevels = map[string]sentry.Level{
+		"INFO":    sentry.LevelInfo,
+		"WARNING": sentry.LevelWarning,
+		"ERROR":   sentry.LevelError,
+		"FATAL":   sentry.LevelFatal,
+	}
+)
+
+// RegisterSeverity maps a glog severity to a Sentry level, for logging setups
+// which extend glog with custom severities. Events of the severity are
+// captured if its level is at least that of the minimum severity set by
+// WithMinSeverity (ERROR by default). It may also be used to change the level
+// of the standard severities.
+func RegisterSeverity(severity string, level sentry.Level) {
+	severityLevelsMu.Lock()
+	defer severityLevelsMu.Unlock()
+	severityLevels[severity] = level
+}
+
+// severityLevel returns the Sentry level of the glog severity, and whether
+// the severity is known.
+func severityLevel(severity string) (sentry.Level, bool) {
+	severityLevelsMu.RLock()
+	defer severityLevelsMu.RUnlock()
+	level, ok := severityLevels[severity]
+	return level, ok
+}
+
+// unknownSeverityOnce warns about the first event with an unknown severity.
+var unknownSeverityOnce sync.Once
+
 // buildLevel converts a glog level to a sentry level.
-// input level is one of: INFO, WARNING, ERROR or FATAL
+// input level is one of: INFO, WARNING, ERROR or FATAL, or a severity
+// registered with RegisterSeverity. Unknown severities are converted to
+// the error level, so that events never have an invalid level.
 func buildLevel(severity string) sentry.Level {
-	return sentry.Level(strings.ToLower(severity))
+	if level, ok := severityLevel(severity); ok {
+		return level
+	}
+	unknownSeverityOnce.Do(func() {
+		// Don't use glog, or we'll just end up in an infinite loop
+		log.Printf("sentry: unknown glog severity %q is captured as an error; use RegisterSeverity to map it", severity)
+	})
+	return sentry.LevelError
 }
@@ -0,0 +1,36 @@
+package sentry
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// The tag set on events whose error chain contains a gRPC status.
+const grpcCodeTag = "grpc_code"
+
+// grpcStatus extracts the code name and message of a gRPC status carried by
+// err, as returned by status.FromError. Errors created by the grpc status
+// package implement GRPCStatus() *status.Status, and the resulting status
+// exposes Code() and Message(). They are called by reflection, so that grpc
+// isn't a dependency.
+func grpcStatus(err error) (code string, msg string, ok bool) {
+	status, ok := callMethod(reflect.ValueOf(err), "GRPCStatus")
+	if !ok || status.Kind() == reflect.Ptr && status.IsNil() {
+		return "", "", false
+	}
+
+	c, ok := callMethod(status, "Code")
+	if !ok || !c.CanInterface() {
+		return "", "", false
+	}
+	stringer, ok := c.Interface().(fmt.Stringer)
+	if !ok {
+		return "", "", false
+	}
+	var m string
+	if message, ok := callMethod(status, "Message"); ok && message.Kind() == reflect.String {
+		m = message.String()
+	}
+
+	return stringer.String(), m, true
+}
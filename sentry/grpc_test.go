@@ -0,0 +1,65 @@
+package sentry_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yext/glog"
+	"github.com/yext/glog-contrib/sentry"
+	"github.com/yext/yerrors"
+)
+
+// The following types mirror the shape of the grpc status and codes packages,
+// which are detected via reflection.
+
+type code uint32
+
+func (c code) String() string {
+	return map[code]string{5: "NotFound", 14: "Unavailable"}[c]
+}
+
+type status struct {
+	code    code
+	message string
+}
+
+func (s *status) Code() code      { return s.code }
+func (s *status) Message() string { return s.message }
+
+type statusError struct {
+	s *status
+}
+
+func (e *statusError) Error() string {
+	if e.s == nil {
+		return "rpc error"
+	}
+	return fmt.Sprintf("rpc error: code = %s desc = %s", e.s.code, e.s.message)
+}
+
+func (e *statusError) GRPCStatus() *status { return e.s }
+
+func TestGrpcStatus(t *testing.T) {
+	err := yerrors.Wrap(&statusError{&status{code: 5, message: "user 1234 not found"}})
+	e, _ := sentry.FromGlogEvent(glog.Event{
+		Severity: "ERROR",
+		Message:  []byte(err.Error()),
+		Data:     []interface{}{glog.ErrorArg{Error: err}},
+	})
+
+	assert.Equal(t, "NotFound", e.Tags["grpc_code"], "tagged with the status code")
+
+	ex := e.Exception[1] // innermost exception is the status error itself
+	assert.Equal(t, "rpc error: NotFound", ex.Type, "type is grouped by code")
+	assert.Equal(t, "user 1234 not found", ex.Value, "value is the status message")
+}
+
+func TestGrpcStatusNil(t *testing.T) {
+	e, _ := sentry.FromGlogEvent(glog.Event{
+		Severity: "ERROR",
+		Data:     []interface{}{glog.ErrorArg{Error: &statusError{}}},
+	})
+
+	assert.NotContains(t, e.Tags, "grpc_code", "nil status is ignored")
+}
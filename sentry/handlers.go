@@ -0,0 +1,208 @@
+package sentry
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+
+	"github.com/getsentry/sentry-go"
+)
+
+// DataHandler converts a value passed to glog into the Sentry event, returning
+// whether the value was handled.
+type DataHandler func(d interface{}, s *sentry.Event) bool
+
+var (
+	dataHandlersMu sync.RWMutex
+	dataHandlers   []*DataHandler
+)
+
+// RegisterDataHandler registers a handler for values passed to glog which are
+// not handled by FromGlogEvent itself, such as proprietary types which should
+// be mapped to the event in a specific way. Handlers are consulted in the order
+// they were registered, until one of them handles the value. It returns a
+// function which unregisters the handler.
+func RegisterDataHandler(h DataHandler) (unregister func()) {
+	dataHandlersMu.Lock()
+	defer dataHandlersMu.Unlock()
+	entry := &h
+	dataHandlers = append(dataHandlers, entry)
+	return func() {
+		dataHandlersMu.Lock()
+		defer dataHandlersMu.Unlock()
+		// The handlers may be in use, so are replaced rather than modified.
+		var handlers []*DataHandler
+		for _, h := range dataHandlers {
+			if h != entry {
+				handlers = append(handlers, h)
+			}
+		}
+		dataHandlers = handlers
+	}
+}
+
+// handleData passes the value to the registered handlers, returning whether
+// any of them handled it.
+func handleData(d interface{}, s *sentry.Event) bool {
+	dataHandlersMu.RLock()
+	handlers := dataHandlers
+	dataHandlersMu.RUnlock()
+
+	for _, h := range handlers {
+		if (*h)(d, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// TagExtractor returns the tags for an error in the chain of an event, and
+// whether the error is of the type it handles.
+type TagExtractor func(err error) (tags map[string]string, ok bool)
+
+var (
+	tagExtractorsMu sync.RWMutex
+	tagExtractors   []*TagExtractor
+)
+
+// RegisterTagExtractor registers an extractor which is run for each error in
+// the chain of an ErrorArg, such as one which tags the event with the fields of
+// a particular error type. The tags from the outermost errors take precedence.
+// It returns a function which unregisters the extractor.
+func RegisterTagExtractor(x TagExtractor) (unregister func()) {
+	tagExtractorsMu.Lock()
+	defer tagExtractorsMu.Unlock()
+	entry := &x
+	tagExtractors = append(tagExtractors, entry)
+	return func() {
+		tagExtractorsMu.Lock()
+		defer tagExtractorsMu.Unlock()
+		var extractors []*TagExtractor
+		for _, x := range tagExtractors {
+			if x != entry {
+				extractors = append(extractors, x)
+			}
+		}
+		tagExtractors = extractors
+	}
+}
+
+// extractTags adds the tags for the error from the registered extractors to
+// the event, unless they have already been set.
+func extractTags(err error, s *sentry.Event) {
+	tagExtractorsMu.RLock()
+	extractors := tagExtractors
+	tagExtractorsMu.RUnlock()
+
+	for _, x := range extractors {
+		tags, ok := (*x)(err)
+		if !ok {
+			continue
+		}
+		for k, v := range tags {
+			if _, ok := s.Tags[k]; !ok {
+				setTag(s, k, v)
+			}
+		}
+	}
+}
+
+// ContextExtractor returns the tags for the context of an event, such as the
+// trace and span IDs of a span stored in it by a tracing library.
+type ContextExtractor func(ctx context.Context) map[string]string
+
+var (
+	contextExtractorsMu sync.RWMutex
+	contextExtractors   []*ContextExtractor
+)
+
+// RegisterContextExtractor registers an extractor which is run for the context
+// of each event with a Context attribute, tagging the event with the values it
+// returns. Tags set by other attributes take precedence. It returns a function
+// which unregisters the extractor.
+func RegisterContextExtractor(x ContextExtractor) (unregister func()) {
+	contextExtractorsMu.Lock()
+	defer contextExtractorsMu.Unlock()
+	entry := &x
+	contextExtractors = append(contextExtractors, entry)
+	return func() {
+		contextExtractorsMu.Lock()
+		defer contextExtractorsMu.Unlock()
+		var extractors []*ContextExtractor
+		for _, x := range contextExtractors {
+			if x != entry {
+				extractors = append(extractors, x)
+			}
+		}
+		contextExtractors = extractors
+	}
+}
+
+// extractContextTags adds the tags for the context from the registered
+// extractors to the event, unless they have already been set. An extractor
+// which panics is skipped.
+func extractContextTags(ctx context.Context, s *sentry.Event) {
+	contextExtractorsMu.RLock()
+	extractors := contextExtractors
+	contextExtractorsMu.RUnlock()
+
+	for _, x := range extractors {
+		for k, v := range runContextExtractor(*x, ctx) {
+			if _, ok := s.Tags[k]; !ok {
+				setTag(s, k, v)
+			}
+		}
+	}
+}
+
+// runContextExtractor runs the extractor, recovering from any panic.
+func runContextExtractor(x ContextExtractor, ctx context.Context) (tags map[string]string) {
+	defer func() {
+		if r := recover(); r != nil {
+			// Don't use glog, or we'll just end up in an infinite loop
+			log.Printf("Recovered from panic extracting tags from context: %v", r)
+			tags = nil
+		}
+	}()
+	return x(ctx)
+}
+
+// sentinelFingerprint is the fingerprint of issues caused by a sentinel error.
+type sentinelFingerprint struct {
+	sentinel error
+	key      string
+}
+
+var (
+	sentinelFingerprintsMu sync.RWMutex
+	sentinelFingerprints   []sentinelFingerprint
+)
+
+// RegisterSentinelFingerprint groups every issue with an error in its chain
+// which is the sentinel error (as reported by errors.Is) into one issue, by
+// using the key as its fingerprint, regardless of any wrapping messages. If
+// errors match multiple sentinels, the first registered takes precedence. A
+// Fingerprint attribute takes precedence over all sentinels.
+func RegisterSentinelFingerprint(sentinel error, key string) {
+	sentinelFingerprintsMu.Lock()
+	defer sentinelFingerprintsMu.Unlock()
+	sentinelFingerprints = append(sentinelFingerprints, sentinelFingerprint{sentinel, key})
+}
+
+// sentinelFingerprintKey returns the key of the first registered sentinel
+// found in the chains of the errors.
+func sentinelFingerprintKey(errs []error) (string, bool) {
+	sentinelFingerprintsMu.RLock()
+	sentinels := sentinelFingerprints
+	sentinelFingerprintsMu.RUnlock()
+
+	for _, f := range sentinels {
+		for _, err := range errs {
+			if errors.Is(err, f.sentinel) {
+				return f.key, true
+			}
+		}
+	}
+	return "", false
+}
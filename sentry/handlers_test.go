@@ -0,0 +1,115 @@
+package sentry_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"testing"
+
+	sentrygo "github.com/getsentry/sentry-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/yext/glog"
+	"github.com/yext/glog-contrib/sentry"
+)
+
+type accountID string
+
+func TestRegisterDataHandler(t *testing.T) {
+	e, _ := sentry.FromGlogEvent(newErrorEvent("test message", accountID("1234")))
+	assert.NotContains(t, e.Tags, "account", "unknown types are ignored")
+
+	t.Cleanup(sentry.RegisterDataHandler(func(d interface{}, s *sentrygo.Event) bool {
+		return false
+	}))
+	unregister := sentry.RegisterDataHandler(func(d interface{}, s *sentrygo.Event) bool {
+		id, ok := d.(accountID)
+		if ok {
+			s.Tags["account"] = string(id)
+		}
+		return ok
+	})
+
+	e, _ = sentry.FromGlogEvent(newErrorEvent("test message", accountID("1234"), sentry.Logger("custom")))
+	assert.Equal(t, "1234", e.Tags["account"])
+	assert.Equal(t, "custom", e.Logger, "built-in attributes are still handled")
+
+	unregister()
+	e, _ = sentry.FromGlogEvent(newErrorEvent("test message", accountID("1234")))
+	assert.NotContains(t, e.Tags, "account", "unregistered handlers are not consulted")
+}
+
+type httpError struct {
+	StatusCode int
+}
+
+func (e *httpError) Error() string { return fmt.Sprintf("http status %d", e.StatusCode) }
+
+func TestRegisterTagExtractor(t *testing.T) {
+	t.Cleanup(sentry.RegisterTagExtractor(func(err error) (map[string]string, bool) {
+		e, ok := err.(*httpError)
+		if !ok {
+			return nil, false
+		}
+		return map[string]string{"http.status_code": strconv.Itoa(e.StatusCode)}, true
+	}))
+
+	err := fmt.Errorf("request failed: %w", fmt.Errorf("retry: %w", &httpError{503}))
+	e, _ := sentry.FromGlogEvent(newErrorEvent("request failed", glog.ErrorArg{Error: err}))
+	assert.Equal(t, "503", e.Tags["http.status_code"])
+
+	err = fmt.Errorf("outer: %w", joinedErrors{&httpError{404}, &httpError{500}})
+	e, _ = sentry.FromGlogEvent(newErrorEvent("outer", glog.ErrorArg{Error: err}))
+	assert.Equal(t, "404", e.Tags["http.status_code"], "first error in the chain takes precedence")
+
+	e, _ = sentry.FromGlogEvent(newErrorEvent("other", glog.ErrorArg{Error: errors.New("other")}))
+	assert.NotContains(t, e.Tags, "http.status_code")
+}
+
+type spanKey struct{}
+
+type span struct {
+	traceID, spanID string
+}
+
+func TestRegisterContextExtractor(t *testing.T) {
+	t.Cleanup(sentry.RegisterContextExtractor(func(ctx context.Context) map[string]string {
+		panic("extractor failed")
+	}))
+	t.Cleanup(sentry.RegisterContextExtractor(func(ctx context.Context) map[string]string {
+		sp, ok := ctx.Value(spanKey{}).(span)
+		if !ok {
+			return nil
+		}
+		return map[string]string{"trace_id": sp.traceID, "span_id": sp.spanID}
+	}))
+
+	ctx := context.WithValue(context.Background(), spanKey{}, span{"abc123", "def456"})
+	e, _ := sentry.FromGlogEvent(newErrorEvent("test message", sentry.Context(ctx)))
+	assert.Equal(t, "abc123", e.Tags["trace_id"], "panicking extractors are skipped")
+	assert.Equal(t, "def456", e.Tags["span_id"])
+
+	e, _ = sentry.FromGlogEvent(newErrorEvent("test message", sentry.Context(context.Background())))
+	assert.NotContains(t, e.Tags, "trace_id")
+}
+
+func TestRegisterSentinelFingerprint(t *testing.T) {
+	sentry.RegisterSentinelFingerprint(io.ErrUnexpectedEOF, "unexpected-eof")
+
+	for _, err := range []error{
+		fmt.Errorf("reading body of request 1234: %w", io.ErrUnexpectedEOF),
+		fmt.Errorf("decode: %w", fmt.Errorf("reading header: %w", io.ErrUnexpectedEOF)),
+	} {
+		e, _ := sentry.FromGlogEvent(newErrorEvent(err.Error(), glog.ErrorArg{Error: err}))
+		assert.Equal(t, []string{"unexpected-eof"}, e.Fingerprint, "errors wrapping the sentinel are grouped by its key")
+	}
+
+	err := fmt.Errorf("reading body: %w", io.ErrUnexpectedEOF)
+	e, _ := sentry.FromGlogEvent(newErrorEvent(err.Error(), glog.ErrorArg{Error: err}, sentry.Fingerprint("custom")))
+	assert.Equal(t, []string{"custom"}, e.Fingerprint, "explicit fingerprint takes precedence")
+
+	err = fmt.Errorf("reading body: %w", io.EOF)
+	e, _ = sentry.FromGlogEvent(newErrorEvent(err.Error(), glog.ErrorArg{Error: err}))
+	assert.NotEqual(t, []string{"unexpected-eof"}, e.Fingerprint)
+}
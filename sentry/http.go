@@ -1,13 +1,17 @@
 package sentry
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
-	"io"
-	"io/ioutil"
 	"net/http"
+	"net/url"
 	"strings"
+	"sync"
 
 	"github.com/getsentry/sentry-go"
+
+	"github.com/yext/glog-contrib/internal/convert"
 )
 
 // HTTP request building code, used to augment the data sent to Sentry
@@ -15,34 +19,222 @@ import (
 
 func buildHttpRequest(r *http.Request) *sentry.Request {
 	return &sentry.Request{
-		URL:         r.URL.String(),
+		URL:         sentryURL(r.URL),
 		Method:      r.Method,
 		Headers:     sentryHeaders(r.Header),
-		Cookies:     r.Header.Get("Cookie"),
-		QueryString: r.URL.RawQuery,
-		Data:        sentryData(r.Body),
-		Env:         nil,
+		QueryString: sentryQueryString(r.URL.RawQuery),
+		Data:        convert.RequestBody(r, getMaxBodySize()),
+		Env:         remoteAddrEnv(r),
+	}
+}
+
+// remoteAddrEnv returns the environment of the request with the (anonymized)
+// IP address of the client, if it is known.
+func remoteAddrEnv(r *http.Request) map[string]string {
+	if ip := remoteIP(r); ip != "" {
+		return map[string]string{"REMOTE_ADDR": ip}
+	}
+	return nil
+}
+
+// The headers which are omitted from events, since they may contain
+// credentials or session identifiers.
+var deniedHeaders = map[string]bool{
+	"Authorization": true,
+	"Cookie":        true,
+	"Set-Cookie":    true,
+}
+
+// The value which replaces those of sensitive headers and parameters.
+const maskedValue = "***"
+
+var (
+	sensitiveMu      sync.RWMutex
+	sensitiveParams  map[string]bool
+	sensitiveHeaders map[string]bool
+)
+
+// SetSensitiveParams sets the names of the query string parameters of a
+// http.Request whose values are masked (such as "api_key"), matched
+// case-insensitively. By default, no parameters are masked.
+func SetSensitiveParams(params []string) {
+	sensitiveMu.Lock()
+	defer sensitiveMu.Unlock()
+	sensitiveParams = lowerSet(params)
+}
+
+// SetSensitiveHeaders sets the names of the headers of a http.Request whose
+// values are masked (such as "X-Api-Key"), matched case-insensitively. The
+// Authorization, Cookie and Set-Cookie headers are always omitted.
+func SetSensitiveHeaders(headers []string) {
+	sensitiveMu.Lock()
+	defer sensitiveMu.Unlock()
+	sensitiveHeaders = lowerSet(headers)
+}
+
+func lowerSet(names []string) map[string]bool {
+	set := make(map[string]bool, len(names))
+	for _, name := range names {
+		set[strings.ToLower(name)] = true
 	}
+	return set
+}
+
+// isSensitiveParam returns whether the values of the parameter are masked.
+func isSensitiveParam(name string) bool {
+	sensitiveMu.RLock()
+	defer sensitiveMu.RUnlock()
+	return sensitiveParams[strings.ToLower(name)]
+}
+
+// isSensitiveHeader returns whether the values of the header are masked.
+func isSensitiveHeader(name string) bool {
+	sensitiveMu.RLock()
+	defer sensitiveMu.RUnlock()
+	return sensitiveHeaders[strings.ToLower(name)]
 }
 
 func sentryHeaders(headers map[string][]string) map[string]string {
 	var m = map[string]string{}
 	for k, v := range headers {
-		// Skip including cookies in the headers.  Cookies have their own section.
-		if k != "Cookie" {
-			m[k] = strings.Join(v, ",")
+		switch {
+		case deniedHeaders[http.CanonicalHeaderKey(k)]:
+		case isSensitiveHeader(k):
+			m[k] = maskedValue
+		default:
+			m[k] = joinValues(v)
 		}
 	}
 	return m
 }
 
-func sentryData(body io.ReadCloser) string {
-	if s, ok := body.(io.Seeker); ok {
-		s.Seek(0, 0)
+// sentryURL renders the URL with the values of any sensitive parameters in
+// its query string masked, leaving the rest of the query string as it was.
+func sentryURL(u *url.URL) string {
+	if u.RawQuery == "" {
+		return u.String()
+	}
+	masked := *u
+	parts := strings.Split(u.RawQuery, "&")
+	for i, part := range parts {
+		k, v, _ := strings.Cut(part, "=")
+		if key, err := url.QueryUnescape(k); err == nil && v != "" && isSensitiveParam(key) {
+			parts[i] = k + "=" + maskedValue
+		}
+	}
+	masked.RawQuery = strings.Join(parts, "&")
+	return masked.String()
+}
+
+// sentryQueryString renders the query string with its keys sorted, and the
+// values of repeated keys joined like those of headers. The values of
+// sensitive parameters are masked.
+func sentryQueryString(rawQuery string) string {
+	return convert.QueryString(rawQuery, joinValues, func(k, v string) string {
+		if v != "" && isSensitiveParam(k) {
+			return maskedValue
+		}
+		return url.QueryEscape(v)
+	})
+}
+
+var (
+	valueSeparatorMu sync.RWMutex
+	valueSeparator   = ","
+)
+
+// SetValueSeparator sets the separator used to join the values of repeated
+// headers and query string parameters of a http.Request, such as "," (the
+// default) or "\n".
+func SetValueSeparator(sep string) {
+	valueSeparatorMu.Lock()
+	defer valueSeparatorMu.Unlock()
+	valueSeparator = sep
+}
+
+func joinValues(v []string) string {
+	valueSeparatorMu.RLock()
+	defer valueSeparatorMu.RUnlock()
+	return strings.Join(v, valueSeparator)
+}
+
+// The default maximum size of the request bodies captured.
+const defaultMaxBodySize = 4 << 10
+
+var (
+	maxBodySizeMu sync.RWMutex
+	maxBodySize   = defaultMaxBodySize
+)
+
+// SetMaxBodySize sets the maximum number of bytes of the body of a
+// http.Request which are captured, which defaults to 4KB. Longer bodies are
+// truncated, with a marker noting the number of bytes omitted. A size of zero
+// or less captures the entire body.
+//
+// A body is only captured if it can be read without consuming it, as when
+// the request has GetBody set or its body is an io.ReadSeeker. The bodies of
+// requests received by a server are not, so to capture one, log a copy of the
+// request with a buffered body.
+func SetMaxBodySize(size int) {
+	maxBodySizeMu.Lock()
+	defer maxBodySizeMu.Unlock()
+	maxBodySize = size
+}
+
+func getMaxBodySize() int {
+	maxBodySizeMu.RLock()
+	defer maxBodySizeMu.RUnlock()
+	return maxBodySize
+}
+
+var (
+	jwtClaimsMu sync.RWMutex
+	jwtClaims   []string
+)
+
+// SetJWTClaims sets the names of the claims which are decoded from the bearer
+// token of a http.Request passed to glog, and attached to the event as tags
+// named "jwt.<claim>". The token is decoded without being verified, and is never
+// attached itself, so only non-sensitive claims (such as "sub") should be listed.
+// By default, no claims are attached.
+func SetJWTClaims(claims []string) {
+	jwtClaimsMu.Lock()
+	defer jwtClaimsMu.Unlock()
+	jwtClaims = claims
+}
+
+// jwtClaimTags decodes the payload of the request's bearer token and returns
+// the allowlisted claims as tags.
+func jwtClaimTags(r *http.Request) map[string]string {
+	jwtClaimsMu.RLock()
+	claims := jwtClaims
+	jwtClaimsMu.RUnlock()
+	if len(claims) == 0 {
+		return nil
 	}
-	b, err := ioutil.ReadAll(body)
+
+	auth := r.Header.Get("Authorization")
+	if len(auth) < 7 || !strings.EqualFold(auth[:7], "Bearer ") {
+		return nil
+	}
+	parts := strings.Split(strings.TrimSpace(auth[7:]), ".")
+	if len(parts) != 3 {
+		return nil
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
 	if err != nil {
-		return fmt.Sprintf("<%v>", err)
+		return nil
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(payload, &decoded); err != nil {
+		return nil
+	}
+
+	tags := map[string]string{}
+	for _, claim := range claims {
+		if v, ok := decoded[claim]; ok {
+			tags["jwt."+claim] = fmt.Sprint(v)
+		}
 	}
-	return string(b)
+	return tags
 }
@@ -0,0 +1,178 @@
+package sentry_test
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yext/glog-contrib/sentry"
+)
+
+func TestJWTClaims(t *testing.T) {
+	token := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`)) + "." +
+		base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"user-1","tenant":"acme","email":"user@example.com"}`)) +
+		".signature"
+	req := httptest.NewRequest("GET", "/path", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	e, _ := sentry.FromGlogEvent(newErrorEvent("test message", req))
+	assert.NotContains(t, e.Tags, "jwt.sub", "no claims are attached by default")
+
+	sentry.SetJWTClaims([]string{"sub", "tenant", "missing"})
+	defer sentry.SetJWTClaims(nil)
+
+	e, _ = sentry.FromGlogEvent(newErrorEvent("test message", req))
+	assert.Equal(t, "user-1", e.Tags["jwt.sub"])
+	assert.Equal(t, "acme", e.Tags["jwt.tenant"])
+	assert.NotContains(t, e.Tags, "jwt.email", "claims which are not allowlisted are not attached")
+	assert.NotContains(t, e.Tags, "jwt.missing")
+	for _, v := range e.Tags {
+		assert.NotContains(t, v, token, "token is not attached")
+	}
+
+	req.Header.Set("Authorization", "Basic dXNlcjpwYXNz")
+	e, _ = sentry.FromGlogEvent(newErrorEvent("test message", req))
+	assert.NotContains(t, e.Tags, "jwt.sub", "only bearer tokens are decoded")
+}
+
+func TestJWTClaimsPrecedence(t *testing.T) {
+	token := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`)) + "." +
+		base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"user-1"}`)) + ".signature"
+	first := httptest.NewRequest("GET", "/first", nil)
+	last := httptest.NewRequest("GET", "/last", nil)
+	last.Header.Set("Authorization", "Bearer "+token)
+
+	sentry.SetJWTClaims([]string{"sub"})
+	defer sentry.SetJWTClaims(nil)
+
+	e, _ := sentry.FromGlogEvent(newErrorEvent("test message",
+		sentry.Tags(map[string]string{"jwt.sub": "explicit"}), last))
+	assert.Equal(t, "explicit", e.Tags["jwt.sub"], "tags override claims regardless of order")
+
+	e, _ = sentry.FromGlogEvent(newErrorEvent("test message", last, first))
+	assert.Equal(t, "/first", e.Request.URL, "the last request is used")
+	assert.NotContains(t, e.Tags, "jwt.sub", "only the claims of the last request are tagged")
+}
+
+func TestRepeatedValues(t *testing.T) {
+	req := httptest.NewRequest("GET", "/path?b=2&a=1&b=x+y", nil)
+	req.Header.Add("Accept", "text/html")
+	req.Header.Add("Accept", "application/json")
+
+	e, _ := sentry.FromGlogEvent(newErrorEvent("test message", req))
+	assert.Equal(t, "text/html,application/json", e.Request.Headers["Accept"])
+	assert.Equal(t, "a=1&b=2,x+y", e.Request.QueryString, "repeated parameters are joined like headers")
+
+	sentry.SetValueSeparator("\n")
+	defer sentry.SetValueSeparator(",")
+
+	e, _ = sentry.FromGlogEvent(newErrorEvent("test message", req))
+	assert.Equal(t, "text/html\napplication/json", e.Request.Headers["Accept"])
+	assert.Equal(t, "a=1&b=2\nx+y", e.Request.QueryString)
+}
+
+func TestDeniedHeaders(t *testing.T) {
+	req := httptest.NewRequest("GET", "/path", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("Cookie", "session=secret")
+	req.Header.Set("Set-Cookie", "session=secret")
+	req.Header.Set("Accept", "text/html")
+
+	e, _ := sentry.FromGlogEvent(newErrorEvent("test message", req))
+	assert.Equal(t, map[string]string{"Accept": "text/html"}, e.Request.Headers, "sensitive headers are omitted")
+	assert.Empty(t, e.Request.Cookies)
+}
+
+func TestRemoteIP(t *testing.T) {
+	defer sentry.SetIPAnonymization(sentry.IPMask)
+	defer sentry.SetUseForwardedFor(false)
+
+	remoteAddr := func(remote, forwardedFor string) string {
+		req := httptest.NewRequest("GET", "/path", nil)
+		req.RemoteAddr = remote
+		if forwardedFor != "" {
+			req.Header.Set("X-Forwarded-For", forwardedFor)
+		}
+		e, _ := sentry.FromGlogEvent(newErrorEvent("test message", req))
+		return e.Request.Env["REMOTE_ADDR"]
+	}
+
+	assert.Equal(t, "203.0.113.0", remoteAddr("203.0.113.7:54321", ""), "IPv4 addresses are masked by default")
+	assert.Equal(t, "2001:db8:85a3::", remoteAddr("[2001:db8:85a3:8d3:1319:8a2e:370:7348]:443", ""), "IPv6 addresses are masked")
+	assert.Equal(t, "203.0.113.0", remoteAddr("203.0.113.7:54321", "198.51.100.1"), "X-Forwarded-For is ignored by default")
+
+	sentry.SetUseForwardedFor(true)
+	assert.Equal(t, "198.51.100.0", remoteAddr("203.0.113.7:54321", "192.0.2.1, 198.51.100.1"), "last hop of X-Forwarded-For is used")
+
+	sentry.SetIPAnonymization(sentry.IPNone)
+	assert.Equal(t, "203.0.113.7", remoteAddr("203.0.113.7:54321", ""))
+	assert.Equal(t, "2001:db8::1", remoteAddr("[2001:db8::1]:443", ""))
+
+	sentry.SetIPAnonymization(sentry.IPHash)
+	hashed := remoteAddr("203.0.113.7:54321", "")
+	assert.Len(t, hashed, 16)
+	assert.NotContains(t, hashed, "203")
+	assert.Equal(t, hashed, remoteAddr("203.0.113.7:1234", ""), "the same address has the same hash")
+	assert.NotEqual(t, hashed, remoteAddr("203.0.113.8:54321", ""))
+}
+
+func TestSensitiveParamsAndHeaders(t *testing.T) {
+	req := httptest.NewRequest("GET", "/path?api_key=abc&page=2&API_KEY=def&token", nil)
+	req.Header.Set("X-Api-Key", "secret")
+	req.Header.Set("Accept", "text/html")
+
+	e, _ := sentry.FromGlogEvent(newErrorEvent("test message", req))
+	assert.Contains(t, e.Request.URL, "api_key=abc", "nothing is masked by default")
+
+	sentry.SetSensitiveParams([]string{"Api_Key", "token"})
+	defer sentry.SetSensitiveParams(nil)
+	sentry.SetSensitiveHeaders([]string{"x-api-key"})
+	defer sentry.SetSensitiveHeaders(nil)
+
+	e, _ = sentry.FromGlogEvent(newErrorEvent("test message", req))
+	assert.Equal(t, "/path?api_key=***&page=2&API_KEY=***&token", e.Request.URL,
+		"sensitive parameters are masked case-insensitively")
+	assert.Equal(t, "API_KEY=***&api_key=***&page=2&token=", e.Request.QueryString)
+	assert.Equal(t, "***", e.Request.Headers["X-Api-Key"])
+	assert.Equal(t, "text/html", e.Request.Headers["Accept"])
+}
+
+// bufferedRequest returns a request with the body, which may be read again
+// without consuming it.
+func bufferedRequest(t *testing.T, body string) *http.Request {
+	req, err := http.NewRequest("POST", "/upload", strings.NewReader(body))
+	require.NoError(t, err)
+	return req
+}
+
+func TestMaxBodySize(t *testing.T) {
+	body := strings.Repeat("a", 5000)
+	e, _ := sentry.FromGlogEvent(newErrorEvent("test message", bufferedRequest(t, body)))
+	assert.Equal(t, strings.Repeat("a", 4096)+"…(truncated 904 bytes)", e.Request.Data, "bodies are truncated to 4KB by default")
+
+	sentry.SetMaxBodySize(10)
+	defer sentry.SetMaxBodySize(4096)
+	e, _ = sentry.FromGlogEvent(newErrorEvent("test message", bufferedRequest(t, "0123456789")))
+	assert.Equal(t, "0123456789", e.Request.Data, "bodies within the limit are not truncated")
+}
+
+func TestBodyNotConsumed(t *testing.T) {
+	body := strings.Repeat("a", 5000)
+	req := bufferedRequest(t, body)
+	for i := 0; i < 2; i++ {
+		e, _ := sentry.FromGlogEvent(newErrorEvent("test message", req))
+		assert.Equal(t, strings.Repeat("a", 4096)+"…(truncated 904 bytes)", e.Request.Data, "the body is captured from the start")
+	}
+
+	b, err := ioutil.ReadAll(req.Body)
+	require.NoError(t, err)
+	assert.Equal(t, body, string(b), "the entire body can be read after capture")
+
+	e, _ := sentry.FromGlogEvent(newErrorEvent("test message", httptest.NewRequest("POST", "/upload", strings.NewReader(body))))
+	assert.Equal(t, "", e.Request.Data, "the body of a server request isn't captured")
+}
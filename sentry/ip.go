@@ -0,0 +1,92 @@
+package sentry
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// IPAnonymization is how the IP address of the client of a request is
+// anonymized before it is attached to an event.
+type IPAnonymization int
+
+const (
+	// IPMask zeroes the last octet of IPv4 addresses, and all but the first
+	// 48 bits of IPv6 addresses.
+	IPMask IPAnonymization = iota
+	// IPHash replaces addresses with a hash of them, which identifies repeat
+	// clients without revealing their addresses.
+	IPHash
+	// IPNone attaches addresses as they are.
+	IPNone
+)
+
+var (
+	ipMu            sync.RWMutex
+	ipAnonymization = IPMask
+	useForwardedFor bool
+)
+
+// SetIPAnonymization sets how the IP address of the client of a request is
+// anonymized, which is IPMask by default.
+func SetIPAnonymization(mode IPAnonymization) {
+	ipMu.Lock()
+	defer ipMu.Unlock()
+	ipAnonymization = mode
+}
+
+// SetUseForwardedFor sets whether the IP address of the client of a request is
+// taken from the last hop of its X-Forwarded-For header, if present, rather
+// than its remote address. This should only be enabled behind a proxy which
+// sets the header.
+func SetUseForwardedFor(use bool) {
+	ipMu.Lock()
+	defer ipMu.Unlock()
+	useForwardedFor = use
+}
+
+// remoteIP returns the anonymized IP address of the client which made the
+// request.
+func remoteIP(r *http.Request) string {
+	ipMu.RLock()
+	mode, forwarded := ipAnonymization, useForwardedFor
+	ipMu.RUnlock()
+
+	addr := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	if xff := r.Header.Get("X-Forwarded-For"); forwarded && xff != "" {
+		hops := strings.Split(xff, ",")
+		addr = strings.TrimSpace(hops[len(hops)-1])
+	}
+	if addr == "" {
+		return ""
+	}
+	return anonymizeIP(addr, mode)
+}
+
+// anonymizeIP anonymizes the IP address with the given mode.
+func anonymizeIP(addr string, mode IPAnonymization) string {
+	switch mode {
+	case IPNone:
+		return addr
+	case IPHash:
+		sum := sha256.Sum256([]byte(addr))
+		return hex.EncodeToString(sum[:8])
+	}
+
+	ip := net.ParseIP(addr)
+	switch {
+	case ip == nil:
+		// Don't leak an address which can't be masked.
+		return ""
+	case ip.To4() != nil:
+		return ip.Mask(net.CIDRMask(24, 32)).String()
+	default:
+		return ip.Mask(net.CIDRMask(48, 128)).String()
+	}
+}
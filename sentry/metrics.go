@@ -0,0 +1,132 @@
+package sentry
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+)
+
+// The name of the counter metric incremented for events above the threshold.
+const metricName = "glog.errors"
+
+// How often the counters for events above the threshold are sent.
+const metricsFlushInterval = 10 * time.Second
+
+// metricAggregator counts the events for each fingerprint within a window, and
+// replaces any events above the threshold with increments of a counter metric.
+type metricAggregator struct {
+	limit  int
+	window time.Duration
+
+	mu        sync.Mutex
+	seen      map[string]*windowCount
+	lastSweep time.Time
+	counters  map[metricKey]int
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+type windowCount struct {
+	start time.Time
+	count int
+}
+
+// metricKey identifies a counter by its tags.
+type metricKey struct {
+	fingerprint string
+	level       sentry.Level
+}
+
+func newMetricAggregator(limit int, window time.Duration) *metricAggregator {
+	m := &metricAggregator{
+		limit:    limit,
+		window:   window,
+		seen:     make(map[string]*windowCount),
+		counters: make(map[metricKey]int),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go m.run()
+	return m
+}
+
+// aggregate returns whether the event is above the threshold for its
+// fingerprint, in which case its counter is incremented.
+func (m *metricAggregator) aggregate(e *sentry.Event, fingerprint []string) bool {
+	key := strings.Join(fingerprint, "\n")
+	now := time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if now.Sub(m.lastSweep) >= m.window {
+		for k, c := range m.seen {
+			if now.Sub(c.start) >= m.window {
+				delete(m.seen, k)
+			}
+		}
+		m.lastSweep = now
+	}
+
+	c, ok := m.seen[key]
+	if !ok || now.Sub(c.start) >= m.window {
+		c = &windowCount{start: now}
+		m.seen[key] = c
+	}
+	c.count++
+	if c.count <= m.limit {
+		return false
+	}
+
+	sum := sha1.Sum([]byte(key))
+	m.counters[metricKey{hex.EncodeToString(sum[:8]), e.Level}]++
+	return true
+}
+
+func (m *metricAggregator) run() {
+	defer close(m.done)
+	ticker := time.NewTicker(metricsFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			m.flush()
+		case <-m.stop:
+			m.flush()
+			return
+		}
+	}
+}
+
+// flush sends the counters to Sentry in the statsd format.
+func (m *metricAggregator) flush() {
+	m.mu.Lock()
+	counters := m.counters
+	m.counters = make(map[metricKey]int)
+	m.mu.Unlock()
+	if len(counters) == 0 {
+		return
+	}
+
+	now := time.Now().Unix()
+	var lines []string
+	for k, n := range counters {
+		lines = append(lines, fmt.Sprintf("%s@none:%d|c|#fingerprint:%s,level:%s|T%d", metricName, n, k.fingerprint, k.level, now))
+	}
+	sort.Strings(lines)
+	if err := sendEnvelope("statsd", []byte(strings.Join(lines, "\n"))); err != nil {
+		sentry.Logger.Printf("Failed to send metrics: %v", err)
+	}
+}
+
+// close sends any remaining counters and stops the aggregator.
+func (m *metricAggregator) close() {
+	close(m.stop)
+	<-m.done
+}
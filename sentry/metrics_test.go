@@ -0,0 +1,59 @@
+package sentry_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	sentrygo "github.com/getsentry/sentry-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yext/glog"
+	"github.com/yext/glog-contrib/sentry"
+)
+
+func TestMetricsAbove(t *testing.T) {
+	var mu sync.Mutex
+	bodies := map[string][]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		bodies[r.URL.Path] = append(bodies[r.URL.Path], string(b))
+	}))
+	defer server.Close()
+
+	var dropped []sentry.DropReason
+	comm := make(chan glog.Event)
+	done := make(chan struct{})
+	go func() {
+		sentry.CaptureErrors("example", []string{strings.Replace(server.URL, "://", "://public@", 1) + "/1"},
+			sentrygo.ClientOptions{}, comm,
+			sentry.WithMetricsAbove(2, time.Minute),
+			sentry.WithOnDropped(func(e *sentrygo.Event, fingerprint []string, reason sentry.DropReason) {
+				dropped = append(dropped, reason)
+			}))
+		close(done)
+	}()
+	for _, e := range repeatedEvents(5, "test message") {
+		comm <- e
+	}
+	close(comm)
+	<-done
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Len(t, bodies["/api/1/store/"], 2, "events up to the threshold are sent")
+	assert.Equal(t, []sentry.DropReason{sentry.DropAggregated, sentry.DropAggregated, sentry.DropAggregated}, dropped)
+
+	require.Len(t, bodies["/api/1/envelope/"], 1, "remaining events are counted")
+	lines := strings.Split(strings.TrimSpace(bodies["/api/1/envelope/"][0]), "\n")
+	require.Len(t, lines, 3)
+	assert.Contains(t, lines[1], `"type":"statsd"`)
+	assert.Regexp(t, regexp.MustCompile(`^glog\.errors@none:3\|c\|#fingerprint:[0-9a-f]{16},level:error\|T\d+$`), lines[2])
+}
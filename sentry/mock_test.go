@@ -0,0 +1,130 @@
+package sentry_test
+
+import (
+	"runtime"
+	"sync"
+	"time"
+
+	sentrygo "github.com/getsentry/sentry-go"
+	"github.com/yext/glog"
+	"github.com/yext/glog-contrib/sentry"
+)
+
+// newErrorEvent builds an ERROR glog event with the given message and data,
+// using the stack trace of the caller (as glog would).
+func newErrorEvent(msg string, data ...interface{}) glog.Event {
+	callers := make([]uintptr, 20)
+	written := runtime.Callers(2, callers)
+	return glog.Event{
+		Severity:   "ERROR",
+		Message:    []byte(msg),
+		Data:       data,
+		StackTrace: callers[:written],
+	}
+}
+
+// transportMock records the events sent by a Sentry client
+// instead of sending them over the network.
+type transportMock struct {
+	mu      sync.Mutex
+	events  []*sentrygo.Event
+	times   []time.Time
+	flushes []time.Duration
+}
+
+func (t *transportMock) Configure(options sentrygo.ClientOptions) {}
+
+func (t *transportMock) SendEvent(event *sentrygo.Event) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.events = append(t.events, event)
+	t.times = append(t.times, time.Now())
+}
+
+func (t *transportMock) Flush(timeout time.Duration) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.flushes = append(t.flushes, timeout)
+	return true
+}
+
+// Flushes returns the number of times the transport was flushed.
+func (t *transportMock) Flushes() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.flushes)
+}
+
+// FlushTimeouts returns the timeout of each flush of the transport.
+func (t *transportMock) FlushTimeouts() []time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]time.Duration(nil), t.flushes...)
+}
+
+func (t *transportMock) Events() []*sentrygo.Event {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]*sentrygo.Event(nil), t.events...)
+}
+
+// SendTimes returns the times at which each event was sent.
+func (t *transportMock) SendTimes() []time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]time.Time(nil), t.times...)
+}
+
+// captureEvents runs CaptureErrors with the given options over the glog
+// events, and returns the events which were sent to Sentry.
+func captureEvents(events []glog.Event, options ...sentry.Option) []*sentrygo.Event {
+	transport := &transportMock{}
+	comm := make(chan glog.Event)
+	done := make(chan struct{})
+	go func() {
+		sentry.CaptureErrors("example", []string{""}, sentrygo.ClientOptions{Transport: transport}, comm, options...)
+		close(done)
+	}()
+
+	for _, e := range events {
+		comm <- e
+	}
+	close(comm)
+	<-done
+
+	return transport.Events()
+}
+
+// countingMetrics counts the calls to each of the Metrics methods.
+type countingMetrics struct {
+	mu                     sync.Mutex
+	received, sent, failed int
+	dropped                map[sentry.DropReason]int
+}
+
+func (m *countingMetrics) Received() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.received++
+}
+
+func (m *countingMetrics) Sent() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent++
+}
+
+func (m *countingMetrics) Dropped(reason sentry.DropReason) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.dropped == nil {
+		m.dropped = make(map[sentry.DropReason]int)
+	}
+	m.dropped[reason]++
+}
+
+func (m *countingMetrics) Failed() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.failed++
+}
@@ -0,0 +1,393 @@
+package sentry
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/yext/glog-contrib/internal/convert"
+)
+
+// Option configures the capture loop run by CaptureErrors.
+type Option func(*options)
+
+type options struct {
+	dedupWindow  time.Duration
+	repeatLevel  sentry.Level
+	dial         func(ctx context.Context, network, addr string) (net.Conn, error)
+	minInterval  time.Duration
+	paceBuffer   int
+	sampleRate   float64
+	detailOnce   time.Duration
+	envTags      map[string]string
+	modules      map[string]string
+	errorFilter  []func(error) bool
+	metricLimit  int
+	metricWindow time.Duration
+	flushTimeout time.Duration
+	maxInFlight  int
+	workers      int
+	workerQueue  int
+	minSeverity  string
+	runtimeTags  bool
+	beforeSend   func(e *sentry.Event) *sentry.Event
+	maxPerSec    int
+	startup      bool
+	profiler     *profiler
+	retry        *retryQueue
+	onCaptured   func(e *sentry.Event, fingerprint []string)
+	onDropped    func(e *sentry.Event, fingerprint []string, reason DropReason)
+	counters     Metrics
+}
+
+// DropReason is the reason an event was not sent to Sentry.
+type DropReason string
+
+// The reasons for which events are dropped.
+const (
+	// DropSampled events were not selected by the sample rate.
+	DropSampled DropReason = "sampled"
+	// DropDuplicate events were repeats within the dedup window.
+	DropDuplicate DropReason = "duplicate"
+	// DropBufferFull events exceeded the buffer of a paced DSN.
+	DropBufferFull DropReason = "buffer_full"
+	// DropAggregated events were counted by a metric rather than sent.
+	DropAggregated DropReason = "aggregated"
+	// DropFiltered events did not match the OnlyIfErrorIs or OnlyIfErrorAs filters.
+	DropFiltered DropReason = "filtered"
+	// DropBeforeSend events were discarded by the WithBeforeSend hook.
+	DropBeforeSend DropReason = "before_send"
+	// DropRateLimited events exceeded the rate set by WithMaxEventsPerSec.
+	DropRateLimited DropReason = "rate_limited"
+	// DropQueueFull events exceeded the queue of the WithWorkers pool.
+	DropQueueFull DropReason = "queue_full"
+	// DropClient events were discarded by the Sentry client, such as by the
+	// SampleRate or BeforeSend of its ClientOptions.
+	DropClient DropReason = "client"
+)
+
+// Metrics is incremented by CaptureErrors as events pass through it, so
+// that its health can be monitored, such as by exporting the counts to
+// Prometheus. Its methods may be called concurrently.
+type Metrics interface {
+	// Received is called for each glog event at a captured severity.
+	Received()
+	// Sent is called for each event passed to a Sentry client.
+	Sent()
+	// Dropped is called for each event which was not sent to Sentry, with
+	// the reason why.
+	Dropped(reason DropReason)
+	// Failed is called for each event which couldn't be converted, or which
+	// the Sentry client failed to deliver (even if it will be retried).
+	// Failures to deliver are only known with WithRetryQueue, since otherwise
+	// events are delivered in the background.
+	Failed()
+}
+
+// noopMetrics is the Metrics used by default, which does nothing.
+type noopMetrics struct{}
+
+func (noopMetrics) Received()          {}
+func (noopMetrics) Sent()              {}
+func (noopMetrics) Dropped(DropReason) {}
+func (noopMetrics) Failed()            {}
+
+// WithDedupWindow enables client-side deduplication of events. After an event
+// is captured, any repeats of it (events with the same fingerprint, or the
+// same exceptions and call site if no fingerprint is set) within the window
+// are dropped. The first occurrence after the window has the number of times
+// the event was seen within it in the extra data ("TimesSeen").
+func WithDedupWindow(window time.Duration) Option {
+	return func(o *options) {
+		o.dedupWindow = window
+	}
+}
+
+// WithRepeatLevel causes repeats of an event within the dedup window to be
+// captured at the given level (such as sentry.LevelWarning) rather than being
+// dropped. This keeps the volume of an issue accurate while only the first
+// occurrence within the window is captured at its original level, such as
+// ERROR, which may trigger alerts.
+func WithRepeatLevel(level sentry.Level) Option {
+	return func(o *options) {
+		o.repeatLevel = level
+	}
+}
+
+// WithDialer sets the function used to open connections to Sentry, such as
+// one which connects to a local agent over a unix socket. The proxy and CA
+// certificates of the ClientOptions (or the proxy set by the environment) are
+// still used. It is ignored if the ClientOptions already specify an
+// HTTPTransport or HTTPClient.
+func WithDialer(dial func(ctx context.Context, network, addr string) (net.Conn, error)) Option {
+	return func(o *options) {
+		o.dial = dial
+	}
+}
+
+// WithMinSendInterval spaces out the events sent to each DSN by at least the
+// given interval, to smooth bursts of errors into a steady trickle. Up to buffer
+// events (or 100, if buffer is not positive) are held for each DSN while waiting
+// to be sent, and any further events are dropped.
+func WithMinSendInterval(interval time.Duration, buffer int) Option {
+	return func(o *options) {
+		o.minInterval = interval
+		o.paceBuffer = buffer
+	}
+}
+
+// WithDetailOnce reduces the size of repeated events. After an event is
+// captured with full detail, any repeats of it within the window are captured
+// with only their message and fingerprint (omitting exceptions, stack traces,
+// requests and extra data), which keeps the volume of the issue accurate. To
+// group repeats with the first event, every event is given its computed
+// fingerprint if it does not have one already.
+func WithDetailOnce(window time.Duration) Option {
+	return func(o *options) {
+		o.detailOnce = window
+	}
+}
+
+// WithSampleRate captures only the given proportion of events (between 0 and
+// 1), selected at random. Unlike the SampleRate of the ClientOptions, events
+// which are not selected are reported to any OnDropped callback.
+func WithSampleRate(rate float64) Option {
+	return func(o *options) {
+		o.sampleRate = rate
+	}
+}
+
+// WithMetrics sets the Metrics which are incremented as events are received,
+// sent, dropped, or fail to send. By default, they aren't recorded.
+func WithMetrics(m Metrics) Option {
+	return func(o *options) {
+		o.counters = m
+	}
+}
+
+// WithOnCaptured sets a callback which is called with each event sent to
+// Sentry, along with the fingerprint used to identify repeats of it.
+func WithOnCaptured(f func(e *sentry.Event, fingerprint []string)) Option {
+	return func(o *options) {
+		o.onCaptured = f
+	}
+}
+
+// WithOnDropped sets a callback which is called with each event which is not
+// sent to Sentry, along with the fingerprint used to identify repeats of it and
+// the reason it was dropped. This may be used to log dropped events elsewhere,
+// or to keep track of the number dropped.
+func WithOnDropped(f func(e *sentry.Event, fingerprint []string, reason DropReason)) Option {
+	return func(o *options) {
+		o.onDropped = f
+	}
+}
+
+// WithTagsFromEnv adds a default tag to each event for every environment
+// variable beginning with the prefix, named by the remainder of the variable
+// (so that SENTRY_TAG_region=us becomes the tag region=us, with a prefix of
+// "SENTRY_TAG_"). Tags set on the event itself take precedence.
+func WithTagsFromEnv(prefix string) Option {
+	return func(o *options) {
+		if o.envTags == nil {
+			o.envTags = map[string]string{}
+		}
+		for k, v := range convert.TagsFromEnv(prefix, os.Environ()) {
+			o.envTags[k] = v
+		}
+	}
+}
+
+// WithModuleVersions attaches the versions of the dependencies of the binary
+// whose module paths begin with any of the prefixes (such as
+// "github.com/yext/") to the extra data of each event, as "Modules". This helps
+// to correlate issues with dependency upgrades. Nothing is attached if the
+// binary was built without module support.
+func WithModuleVersions(prefixes ...string) Option {
+	return func(o *options) {
+		info, _ := debug.ReadBuildInfo()
+		o.modules = moduleVersions(info, prefixes)
+	}
+}
+
+// moduleVersions returns the versions of the dependencies in the build info
+// with any of the prefixes, taking replacements into account.
+func moduleVersions(info *debug.BuildInfo, prefixes []string) map[string]string {
+	if info == nil {
+		return nil
+	}
+	modules := map[string]string{}
+	for _, dep := range info.Deps {
+		for _, prefix := range prefixes {
+			if !strings.HasPrefix(dep.Path, prefix) {
+				continue
+			}
+			switch {
+			case dep.Replace == nil:
+				modules[dep.Path] = dep.Version
+			case dep.Replace.Version == "":
+				modules[dep.Path] = dep.Replace.Path
+			default:
+				modules[dep.Path] = dep.Replace.Path + " " + dep.Replace.Version
+			}
+			break
+		}
+	}
+	return modules
+}
+
+// OnlyIfErrorIs only captures events with an error in their chain which is
+// target, as reported by errors.Is. If multiple filters are provided, events
+// matching any of them are captured. By default, all events are captured.
+func OnlyIfErrorIs(target error) Option {
+	return OnlyIfErrorAs(func(err error) bool {
+		return errors.Is(err, target)
+	})
+}
+
+// OnlyIfErrorAs only captures events with an error in their chain for which
+// match returns true, such as one of a particular type. If multiple filters
+// are provided, events matching any of them are captured. By default, all
+// events are captured.
+func OnlyIfErrorAs(match func(error) bool) Option {
+	return func(o *options) {
+		o.errorFilter = append(o.errorFilter, match)
+	}
+}
+
+// WithMetricsAbove limits the events sent for each fingerprint to the given
+// number within each window. Any further events within the window increment
+// a "glog.errors" counter metric (tagged with a hash of the fingerprint and
+// the level) in the primary DSN instead, which keeps track of the volume of
+// high-volume errors without sending an event for each of them.
+func WithMetricsAbove(limit int, window time.Duration) Option {
+	return func(o *options) {
+		o.metricLimit = limit
+		o.metricWindow = window
+	}
+}
+
+// WithFlushTimeout sets how long to wait for pending events to be sent to each
+// DSN when capturing stops. Defaults to one second.
+func WithFlushTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.flushTimeout = timeout
+	}
+}
+
+// WithMaxInFlight limits the number of events being sent to Sentry at the same
+// time, across all DSNs, to bound the outbound concurrency when events are
+// sent in parallel (such as to multiple paced DSNs). Further sends wait until
+// one of the others completes.
+func WithMaxInFlight(n int) Option {
+	return func(o *options) {
+		o.maxInFlight = n
+	}
+}
+
+// WithWorkers captures events on the given number of goroutines, rather than
+// on the one reading from the glog channel, so that a slow or unreachable
+// Sentry host doesn't block logging. Events wait in a queue of the given size
+// (1000 if it isn't positive) for a worker, and are dropped if it's full.
+// Fatal events bypass the queue. Any OnCaptured and OnDropped callbacks may
+// then be called concurrently.
+func WithWorkers(n, queue int) Option {
+	return func(o *options) {
+		o.workers = n
+		o.workerQueue = queue
+	}
+}
+
+// WithMinSeverity sets the least severe glog events which are captured, which
+// defaults to "ERROR". With "WARNING", warnings are also captured, as messages
+// at the warning level rather than as exceptions, so that they are grouped
+// separately from errors. FATAL events are always captured, and are flushed
+// immediately since the process is about to exit. The severity must be a
+// standard one or registered with RegisterSeverity before capturing starts,
+// or CaptureErrorsE returns an error.
+func WithMinSeverity(severity string) Option {
+	return func(o *options) {
+		o.minSeverity = severity
+	}
+}
+
+// WithRuntimeTags tags each event with the number of goroutines
+// ("goroutines") and the duration of the most recent garbage collection pause
+// in nanoseconds ("last_gc_pause_ns") at the time it was captured, which can
+// help to diagnose timeouts. Only statistics which are cheap to read are used.
+func WithRuntimeTags() Option {
+	return func(o *options) {
+		o.runtimeTags = true
+	}
+}
+
+// WithBeforeSend sets a hook which is called with each event before it is
+// sent to Sentry, which may modify it (such as to redact secrets from the
+// request or extra data) or return nil to discard it. Unlike the BeforeSend of
+// the ClientOptions, discarded events are reported to any OnDropped callback.
+func WithBeforeSend(f func(e *sentry.Event) *sentry.Event) Option {
+	return func(o *options) {
+		o.beforeSend = f
+	}
+}
+
+// WithMaxEventsPerSec limits the events sent to Sentry to the given rate
+// (with bursts of up to the same number of events), as the gelf backend does,
+// so that an error in a tight loop can't exhaust the quota of the project.
+// Events beyond the rate are dropped, and the number dropped since the last
+// event was sent is added to the extra data ("RateLimited") of the next one.
+func WithMaxEventsPerSec(n int) Option {
+	return func(o *options) {
+		o.maxPerSec = n
+	}
+}
+
+// WithStartupEvent sends an informational event (tagged startup) to each DSN
+// when capturing starts, which confirms at deploy time that events are
+// delivered to Sentry, rather than waiting for the first real error.
+func WithStartupEvent() Option {
+	return func(o *options) {
+		o.startup = true
+	}
+}
+
+// WithProfiling attaches a profile taken by the profiler (such as CPUProfile
+// or HeapProfile) to captured events which match, or to all events if match
+// is nil, to help diagnose errors related to latency or memory. Since
+// profiling has overhead, only one profile is taken at a time, and at most
+// one within each interval; other events are captured without a profile.
+func WithProfiling(p Profiler, interval time.Duration, match func(e *sentry.Event) bool) Option {
+	return func(o *options) {
+		o.profiler = &profiler{profile: p, interval: interval, match: match}
+	}
+}
+
+// WithRetryQueue persists events which fail to send (because Sentry can't be
+// reached, is unavailable, or is rate limiting) as JSON lines in the file at
+// the path, and sends them again once an event is sent successfully or every
+// 30 seconds, such as after an outage. Failed events are appended to the
+// file, and the oldest events are only discarded once it exceeds maxBytes
+// (10MB if it isn't positive). Corrupt lines are skipped. Events
+// are sent synchronously, so this may be combined with WithWorkers to avoid
+// blocking the capture loop. It has no effect if the ClientOptions set a
+// Transport.
+func WithRetryQueue(path string, maxBytes int64) Option {
+	return func(o *options) {
+		o.retry = newRetryQueue(path, maxBytes)
+	}
+}
+
+// clientOptions applies the options which configure the Sentry clients.
+func (o options) clientOptions(opts sentry.ClientOptions) sentry.ClientOptions {
+	if o.dial != nil && opts.HTTPTransport == nil && opts.HTTPClient == nil {
+		t := httpTransport(opts)
+		t.DialContext = o.dial
+		opts.HTTPTransport = t
+	}
+	return opts
+}
@@ -0,0 +1,66 @@
+package sentry
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+)
+
+// The default number of events buffered while pacing sends to a DSN.
+const defaultPaceBuffer = 100
+
+// pacer spaces out the sends to a DSN by a minimum interval, buffering
+// events in the meantime.
+type pacer struct {
+	interval time.Duration
+	queue    chan func()
+	done     chan struct{}
+	dropped  uint64
+}
+
+func newPacer(interval time.Duration, buffer int) *pacer {
+	if buffer <= 0 {
+		buffer = defaultPaceBuffer
+	}
+	p := &pacer{
+		interval: interval,
+		queue:    make(chan func(), buffer),
+		done:     make(chan struct{}),
+	}
+	go p.run()
+	return p
+}
+
+func (p *pacer) run() {
+	defer close(p.done)
+	var last time.Time
+	for send := range p.queue {
+		if wait := p.interval - time.Since(last); !last.IsZero() && wait > 0 {
+			time.Sleep(wait)
+		}
+		send()
+		last = time.Now()
+	}
+}
+
+// offer queues the send without blocking, dropping it if the buffer is full.
+// It returns whether the send was queued.
+func (p *pacer) offer(send func()) bool {
+	select {
+	case p.queue <- send:
+		return true
+	default:
+		atomic.AddUint64(&p.dropped, 1)
+		return false
+	}
+}
+
+// close sends any buffered events and stops the pacer.
+func (p *pacer) close() {
+	close(p.queue)
+	<-p.done
+	if dropped := atomic.LoadUint64(&p.dropped); dropped > 0 {
+		sentry.Logger.Printf("Dropped %d events exceeding the send buffer", dropped)
+	}
+}
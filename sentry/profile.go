@@ -0,0 +1,91 @@
+package sentry
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"runtime/pprof"
+	"sync"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+)
+
+// Profiler writes a profile of the process in the pprof format, such as a CPU
+// profile or a heap snapshot, to attach to an event.
+type Profiler func(w io.Writer) error
+
+// CPUProfile returns a Profiler which profiles the CPU usage of the process
+// for the duration, starting when the event is captured.
+func CPUProfile(d time.Duration) Profiler {
+	return func(w io.Writer) error {
+		if err := pprof.StartCPUProfile(w); err != nil {
+			return err
+		}
+		time.Sleep(d)
+		pprof.StopCPUProfile()
+		return nil
+	}
+}
+
+// HeapProfile is a Profiler which writes a snapshot of the heap.
+func HeapProfile(w io.Writer) error {
+	return pprof.Lookup("heap").WriteTo(w, 0)
+}
+
+// The name of the file attached to events with their profile.
+const profileFilename = "profile.pb.gz"
+
+// profiler attaches profiles to events, taking at most one at a time and
+// waiting at least the interval between them, since profiling has overhead.
+type profiler struct {
+	profile  Profiler
+	interval time.Duration
+	match    func(e *sentry.Event) bool
+
+	mu      sync.Mutex
+	running bool
+	last    time.Time
+	wg      sync.WaitGroup
+}
+
+// attach starts taking a profile to attach to the event, which was captured
+// with the given ID by a client with the options, if the event matches and
+// a profile may be taken now.
+func (p *profiler) attach(opts sentry.ClientOptions, e *sentry.Event, id sentry.EventID) {
+	if p.match != nil && !p.match(e) {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.running || (!p.last.IsZero() && time.Since(p.last) < p.interval) {
+		return
+	}
+	p.running, p.last = true, time.Now()
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		defer func() {
+			p.mu.Lock()
+			p.running = false
+			p.mu.Unlock()
+		}()
+
+		var buf bytes.Buffer
+		if err := p.profile(&buf); err != nil {
+			// Don't use glog, or we'll just end up in an infinite loop
+			log.Printf("Failed to profile for Sentry event %s: %v", id, err)
+			return
+		}
+		if err := sendAttachment(opts, id, profileFilename, "application/octet-stream", buf.Bytes()); err != nil {
+			log.Printf("Failed to attach profile to Sentry event %s: %v", id, err)
+		}
+	}()
+}
+
+// wait waits for the profile being taken, if any, to be attached.
+func (p *profiler) wait() {
+	p.wg.Wait()
+}
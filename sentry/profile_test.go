@@ -0,0 +1,64 @@
+package sentry_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	sentrygo "github.com/getsentry/sentry-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yext/glog"
+	"github.com/yext/glog-contrib/sentry"
+)
+
+func TestWithProfiling(t *testing.T) {
+	envelopes := make(chan []string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var lines []string
+		for scanner := bufio.NewScanner(r.Body); scanner.Scan(); {
+			lines = append(lines, scanner.Text())
+		}
+		envelopes <- lines
+	}))
+	defer server.Close()
+	dsn := strings.Replace(server.URL, "://", "://public@", 1) + "/1"
+
+	profiles := 0
+	stub := func(w io.Writer) error {
+		profiles++
+		_, err := w.Write([]byte("profile"))
+		return err
+	}
+	transport := &transportMock{}
+	comm := make(chan glog.Event, 3)
+	comm <- newErrorEvent("slow request")
+	comm <- newErrorEvent("slow request")
+	comm <- newErrorEvent("other error")
+	close(comm)
+	sentry.CaptureErrors("example", []string{dsn}, sentrygo.ClientOptions{Transport: transport}, comm,
+		sentry.WithProfiling(stub, time.Hour, func(e *sentrygo.Event) bool {
+			return strings.Contains(e.Message, "slow")
+		}))
+
+	events := transport.Events()
+	require.Len(t, events, 3)
+	assert.Equal(t, 1, profiles, "profiles are limited to one per interval")
+
+	require.Len(t, envelopes, 1)
+	lines := <-envelopes
+	require.Len(t, lines, 3, "envelope header, item header and payload")
+	var header, item map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &header))
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &item))
+	assert.Equal(t, string(events[0].EventID), header["event_id"], "the profile is attached to the event")
+	assert.Equal(t, "attachment", item["type"])
+	assert.Equal(t, "profile.pb.gz", item["filename"])
+	assert.EqualValues(t, len("profile"), item["length"])
+	assert.Equal(t, "profile", lines[2])
+}
@@ -0,0 +1,82 @@
+package sentry
+
+import (
+	"fmt"
+	"net/http"
+	"runtime"
+	"strings"
+
+	"github.com/yext/glog"
+)
+
+// The tag set to the mechanism by which an error was captured, such as "panic".
+const mechanismTag = "mechanism"
+
+// The tag set to whether an error was handled, either "yes" or "no". It isn't
+// named "handled", which Sentry reserves for the handled flag of an
+// exception's mechanism.
+const handledTag = "error_handled"
+
+type mechanism string
+
+// Recoverer returns middleware which recovers panics from the next handler and
+// logs them to glog as errors along with the request, so that they are sent to
+// Sentry by CaptureErrors. It then responds with a 500 Internal Server Error.
+func Recoverer(next http.Handler) http.Handler {
+	return recoverer(next, false)
+}
+
+// RecovererRepanic is like Recoverer, but re-panics after logging the panic
+// rather than responding, for servers with recovery of their own.
+func RecovererRepanic(next http.Handler) http.Handler {
+	return recoverer(next, true)
+}
+
+func recoverer(next http.Handler, repanic bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			// This is used to abort a handler without logging.
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+
+			err, ok := v.(error)
+			if !ok {
+				err = fmt.Errorf("%v", v)
+			}
+			glog.ErrorWithDepth(panicDepth(), "panic: ", err, glog.Data(r), glog.Data(mechanism("panic")))
+
+			if repanic {
+				panic(v)
+			}
+			w.WriteHeader(http.StatusInternalServerError)
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// panicDepth returns the number of frames between the caller (a deferred
+// function) and the function which panicked, so that the stack trace of the
+// event begins where the panic occurred.
+func panicDepth() int {
+	callers := make([]uintptr, 50)
+	frames := runtime.CallersFrames(callers[:runtime.Callers(2, callers)])
+
+	depth, panicking := 0, false
+	for {
+		frame, more := frames.Next()
+		if frame.Function == "runtime.gopanic" {
+			panicking = true
+		} else if panicking && !strings.HasPrefix(frame.Function, "runtime.") {
+			return depth
+		}
+		if !more {
+			return 0
+		}
+		depth++
+	}
+}
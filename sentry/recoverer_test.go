@@ -0,0 +1,68 @@
+package sentry_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yext/glog"
+	"github.com/yext/glog-contrib/sentry"
+)
+
+var (
+	glogEventsOnce sync.Once
+	glogEvents     <-chan glog.Event
+)
+
+// nextPanicEvent returns the next event logged to glog for a panic.
+func nextPanicEvent(t *testing.T) glog.Event {
+	for {
+		select {
+		case e := <-glogEvents:
+			if strings.Contains(string(e.Message), "panic: ") {
+				return e
+			}
+		case <-time.After(time.Second):
+			t.Fatal("panic was not logged")
+		}
+	}
+}
+
+func panickingHandler(w http.ResponseWriter, r *http.Request) {
+	panic("handler failed")
+}
+
+func TestRecoverer(t *testing.T) {
+	glogEventsOnce.Do(func() { glogEvents = glog.RegisterBackend() })
+
+	w := httptest.NewRecorder()
+	sentry.Recoverer(http.HandlerFunc(panickingHandler)).ServeHTTP(w, httptest.NewRequest("GET", "/path", nil))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+
+	e, _ := sentry.FromGlogEvent(nextPanicEvent(t))
+	assert.Equal(t, "handler failed\npanic: handler failed", e.Message)
+	assert.Equal(t, "panic", e.Tags["mechanism"])
+	assert.Equal(t, "no", e.Tags["error_handled"], "panics are unhandled")
+	require.NotNil(t, e.Request)
+	assert.Equal(t, "GET", e.Request.Method)
+	assert.Equal(t, "panickingHandler", e.Exception[0].Stacktrace.Frames[len(e.Exception[0].Stacktrace.Frames)-1].Function,
+		"stack trace begins where the panic occurred")
+}
+
+func TestRecovererRepanic(t *testing.T) {
+	glogEventsOnce.Do(func() { glogEvents = glog.RegisterBackend() })
+
+	handler := sentry.RecovererRepanic(http.HandlerFunc(panickingHandler))
+	assert.PanicsWithValue(t, "handler failed", func() {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/path", nil))
+	})
+
+	e, _ := sentry.FromGlogEvent(nextPanicEvent(t))
+	assert.Equal(t, "handler failed\npanic: handler failed", e.Message)
+	assert.Equal(t, "panic", e.Tags["mechanism"])
+}
@@ -0,0 +1,41 @@
+package sentry
+
+import "reflect"
+
+// Data is extracted from errors defined by other packages (such as gRPC
+// statuses, SQL driver errors and errors with fields) by reflection, to avoid
+// a hard dependency on those packages for all users of this package. The
+// types of those errors are only known by convention, so rather than checking
+// every assumption, these helpers recover from any panic of the reflect
+// package and report that the value wasn't found.
+
+// callMethod calls the method of v with the name, which must take no
+// arguments and return one value, and returns that value. It returns false
+// if v has no such method, or if looking it up or calling it panics.
+func callMethod(v reflect.Value, name string) (result reflect.Value, ok bool) {
+	defer func() {
+		if recover() != nil {
+			result, ok = reflect.Value{}, false
+		}
+	}()
+
+	method := v.MethodByName(name)
+	if !method.IsValid() || method.Type().NumIn() != 0 || method.Type().NumOut() != 1 {
+		return reflect.Value{}, false
+	}
+	return method.Call(nil)[0], true
+}
+
+// fieldByName returns the field of the struct v with the name. It returns
+// false if v has no such field, or if looking it up panics (such as when it
+// is promoted through a nil embedded pointer).
+func fieldByName(v reflect.Value, name string) (field reflect.Value, ok bool) {
+	defer func() {
+		if recover() != nil {
+			field, ok = reflect.Value{}, false
+		}
+	}()
+
+	field = v.FieldByName(name)
+	return field, field.IsValid()
+}
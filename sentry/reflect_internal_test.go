@@ -0,0 +1,49 @@
+package sentry
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type reflected struct {
+	*embedded
+	Name string
+}
+
+type embedded struct {
+	Promoted string
+}
+
+func (reflected) Value() string        { return "value" }
+func (reflected) Panics() string       { panic("unexpected") }
+func (reflected) Args(s string) string { return s }
+
+func TestCallMethod(t *testing.T) {
+	v := reflect.ValueOf(reflected{})
+
+	result, ok := callMethod(v, "Value")
+	assert.True(t, ok)
+	assert.Equal(t, "value", result.String())
+
+	for _, name := range []string{"Missing", "Panics", "Args"} {
+		_, ok := callMethod(v, name)
+		assert.False(t, ok, name)
+	}
+	_, ok = callMethod(reflect.Value{}, "Value")
+	assert.False(t, ok, "invalid value")
+}
+
+func TestFieldByName(t *testing.T) {
+	v := reflect.ValueOf(reflected{Name: "name"})
+
+	field, ok := fieldByName(v, "Name")
+	assert.True(t, ok)
+	assert.Equal(t, "name", field.String())
+
+	_, ok = fieldByName(v, "Missing")
+	assert.False(t, ok)
+	_, ok = fieldByName(v, "Promoted")
+	assert.False(t, ok, "promoted through a nil pointer")
+}
@@ -0,0 +1,88 @@
+package sentry
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+)
+
+// The maximum size of a single JSON-lines event read by ReplayFile.
+const maxReplayLineSize = 1 << 20
+
+// How long ReplayFile waits for a transport passed in its options to send
+// the events.
+const replayFlushTimeout = 5 * time.Second
+
+// ReplayFile reads events which were previously written as JSON lines and
+// sends them to the given DSN. This allows events captured while offline to
+// be forwarded later, or reproduced in a test project. Malformed lines are
+// logged and skipped.
+//
+// Each line is a sentry.Event encoded as JSON, as by json.Marshal, which is
+// how the Sentry client encodes events to send them; blank lines are ignored.
+// This package doesn't write such files itself: they may be written from the
+// events passed to the callbacks of WithOnDropped or WithOnCaptured, for
+// example.
+//
+// Unless the options have a Transport, each event is sent before the next is
+// read, so none are dropped for want of buffer space. It returns the number of
+// events sent, or an error if the file could not be read, the client could not
+// be initialized, or the transport in the options didn't finish sending the
+// events in time. Events which Sentry is rate limiting are dropped by the
+// transport, but still counted as sent.
+func ReplayFile(path, dsn string, opts sentry.ClientOptions) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	if opts.Transport == nil {
+		opts.Transport = sentry.NewHTTPSyncTransport()
+	}
+	clientOpts, err := buildClientOptions(dsn, opts)
+	if err != nil {
+		return 0, err
+	}
+	client, err := sentry.NewClient(clientOpts)
+	if err != nil {
+		return 0, err
+	}
+	hub := sentry.NewHub(client, sentry.NewScope())
+
+	sent := 0
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, maxReplayLineSize)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var e sentry.Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			// Don't use glog, since it may be routed back to Sentry
+			log.Printf("Skipping malformed event on line %d of %s: %v", line, path, err)
+			continue
+		}
+		if e.Message == "" && len(e.Exception) == 0 {
+			log.Printf("Skipping empty event on line %d of %s", line, path)
+			continue
+		}
+
+		if hub.CaptureEvent(&e) != nil {
+			sent++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return sent, err
+	}
+	if !client.Flush(replayFlushTimeout) {
+		return sent, errors.New("sentry: timed out sending replayed events")
+	}
+	return sent, nil
+}
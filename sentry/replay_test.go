@@ -0,0 +1,73 @@
+package sentry_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	sentrygo "github.com/getsentry/sentry-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yext/glog"
+	"github.com/yext/glog-contrib/sentry"
+)
+
+func TestReplayFile(t *testing.T) {
+	first, _ := sentry.FromGlogEvent(glog.Event{Severity: "ERROR", Message: []byte("first message")})
+	second, _ := sentry.FromGlogEvent(glog.Event{Severity: "ERROR", Message: []byte("second message")})
+
+	var lines []string
+	for _, e := range []*sentrygo.Event{first, second} {
+		b, err := json.Marshal(e)
+		require.NoError(t, err)
+		lines = append(lines, string(b))
+	}
+	lines = append(lines[:1], `{"message": truncated`, "", `{}`, lines[1])
+
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	require.NoError(t, os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0644))
+
+	transport := &transportMock{}
+	sent, err := sentry.ReplayFile(path, "", sentrygo.ClientOptions{Transport: transport})
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, sent, "malformed and empty lines are skipped")
+	events := transport.Events()
+	require.Len(t, events, 2)
+	assert.Equal(t, "first message", events[0].Message)
+	assert.Equal(t, "second message", events[1].Message)
+	assert.Equal(t, first.Exception[0].Type, events[0].Exception[0].Type)
+}
+
+func TestReplayFileSendsEveryEvent(t *testing.T) {
+	var received int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&received, 1)
+	}))
+	defer server.Close()
+	dsn := strings.Replace(server.URL, "://", "://public@", 1) + "/1"
+
+	// More events than the buffer of the default transport of the Sentry
+	// client holds.
+	const count = 100
+	e, _ := sentry.FromGlogEvent(glog.Event{Severity: "ERROR", Message: []byte("test message")})
+	b, err := json.Marshal(e)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	require.NoError(t, os.WriteFile(path, []byte(strings.Repeat(string(b)+"\n", count)), 0644))
+
+	sent, err := sentry.ReplayFile(path, dsn, sentrygo.ClientOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, count, sent)
+	assert.EqualValues(t, count, atomic.LoadInt64(&received), "every event is sent")
+}
+
+func TestReplayFileMissing(t *testing.T) {
+	_, err := sentry.ReplayFile(filepath.Join(t.TempDir(), "missing.jsonl"), "", sentrygo.ClientOptions{})
+	assert.Error(t, err)
+}
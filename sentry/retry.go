@@ -0,0 +1,322 @@
+package sentry
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+)
+
+// The default limit on the size of the retry queue.
+const defaultRetryQueueSize = 10 << 20
+
+// The interval at which queued events are sent again, so that they are sent
+// once Sentry is reachable even if no new events are captured.
+const defaultRetryInterval = 30 * time.Second
+
+// retryQueue persists events which failed to send as JSON lines in a file,
+// and sends them again once a send succeeds or periodically. The oldest
+// events are discarded to keep the file within its maximum size.
+type retryQueue struct {
+	path     string
+	maxBytes int64
+	interval time.Duration
+
+	mu sync.Mutex
+	// Whether the file may contain events, to avoid reading it after each
+	// successful send.
+	pending bool
+	// The size of the file, so that it is only rewritten once it exceeds
+	// maxBytes.
+	size int64
+	// Whether the queue is being drained, and the entries pushed since the
+	// drain began, which follow those being sent.
+	draining bool
+	pushed   [][]byte
+
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+// retryEntry is an event in the retry queue, with the DSN to send it to.
+type retryEntry struct {
+	Dsn   string          `json:"dsn"`
+	Type  string          `json:"type"`
+	Event json.RawMessage `json:"event"`
+}
+
+func newRetryQueue(path string, maxBytes int64) *retryQueue {
+	if maxBytes <= 0 {
+		maxBytes = defaultRetryQueueSize
+	}
+	q := &retryQueue{path: path, maxBytes: maxBytes, interval: defaultRetryInterval}
+	// Events left by a previous process are sent once Sentry is reachable.
+	if info, err := os.Stat(path); err == nil && info.Size() > 0 {
+		q.pending = true
+		q.size = info.Size()
+	}
+	return q
+}
+
+// start sends the queued entries with send at each interval, until stop is
+// called.
+func (q *retryQueue) start(send func(retryEntry) error) {
+	q.done = make(chan struct{})
+	q.stopped = make(chan struct{})
+	go func() {
+		defer close(q.stopped)
+		ticker := time.NewTicker(q.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := q.drain(send); err != nil {
+					sentry.Logger.Printf("Failed to update the retry queue: %v", err)
+				}
+			case <-q.done:
+				return
+			}
+		}
+	}()
+}
+
+// stop stops the goroutine started by start, if any.
+func (q *retryQueue) stop() {
+	if q.done == nil {
+		return
+	}
+	close(q.done)
+	<-q.stopped
+}
+
+// push appends the entry to the end of the queue. If the queue would exceed
+// its maximum size, it is compacted by discarding the oldest entries.
+func (q *retryQueue) push(entry []byte) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if int64(len(entry))+1 > q.maxBytes {
+		return errors.New("event exceeds the size of the retry queue")
+	}
+	if q.draining {
+		q.pushed = append(q.pushed, entry)
+	}
+	if q.size+int64(len(entry))+1 <= q.maxBytes {
+		return q.append(entry)
+	}
+	return q.compact(append(q.read(), entry))
+}
+
+// drain sends the queued entries in order, stopping at the first which fails
+// to send, and then removes those which were sent from the queue. The queue
+// isn't locked while sending, so that events may be pushed in the meantime,
+// and only one drain sends the entries at a time.
+func (q *retryQueue) drain(send func(retryEntry) error) error {
+	q.mu.Lock()
+	if !q.pending || q.draining {
+		q.mu.Unlock()
+		return nil
+	}
+	entries := q.read()
+	q.draining, q.pushed = true, nil
+	q.mu.Unlock()
+
+	sent := 0
+	for _, line := range entries {
+		var e retryEntry
+		// Entries were valid when written, and read validates them again.
+		json.Unmarshal(line, &e)
+		if err := send(e); err != nil {
+			if retryable(err) {
+				break
+			}
+			sentry.Logger.Printf("Discarding queued event: %v", err)
+		}
+		sent++
+	}
+
+	// The file is only rewritten once the entries have been sent, so that
+	// none are lost if the process exits while sending. Those sent again
+	// after a restart keep their event IDs, so are discarded by Sentry as
+	// duplicates. The entries which weren't sent are older than any pushed
+	// while sending, so they go first.
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	pushed := q.pushed
+	q.draining, q.pushed = false, nil
+	return q.compact(append(entries[sent:], pushed...))
+}
+
+// compact replaces the contents of the file with the entries, discarding the
+// oldest to keep it within its maximum size.
+func (q *retryQueue) compact(entries [][]byte) error {
+	var size int64
+	for _, e := range entries {
+		size += int64(len(e)) + 1
+	}
+	discarded := 0
+	for size > q.maxBytes {
+		size -= int64(len(entries[discarded])) + 1
+		discarded++
+	}
+	if discarded > 0 {
+		sentry.Logger.Printf("Discarded %d events exceeding the retry queue", discarded)
+	}
+	return q.write(entries[discarded:])
+}
+
+// read returns the valid entries in the file. Corrupt entries (such as a
+// line partially written before a crash) are logged and skipped.
+func (q *retryQueue) read() [][]byte {
+	f, err := os.Open(q.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			sentry.Logger.Printf("Failed to read the retry queue: %v", err)
+		}
+		return nil
+	}
+	defer f.Close()
+
+	var entries [][]byte
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, maxReplayLineSize)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var e retryEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil || e.Dsn == "" || len(e.Event) == 0 {
+			sentry.Logger.Printf("Skipping corrupt event on line %d of %s", line, q.path)
+			continue
+		}
+		entries = append(entries, append([]byte(nil), scanner.Bytes()...))
+	}
+	if err := scanner.Err(); err != nil {
+		sentry.Logger.Printf("Failed to read the retry queue: %v", err)
+	}
+	return entries
+}
+
+// append writes the entry to the end of the file. A line left partially
+// written by a crash is skipped when the file is read.
+func (q *retryQueue) append(entry []byte) error {
+	f, err := os.OpenFile(q.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	n, err := f.Write(append(append([]byte(nil), entry...), '\n'))
+	q.size += int64(n)
+	q.pending = true
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// write replaces the contents of the file with the entries. The file is
+// replaced by renaming, so that it is never left partially written.
+func (q *retryQueue) write(entries [][]byte) error {
+	q.pending = len(entries) > 0
+	q.size = 0
+	if len(entries) == 0 {
+		if err := os.Remove(q.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	var buf bytes.Buffer
+	for _, e := range entries {
+		buf.Write(e)
+		buf.WriteByte('\n')
+	}
+	tmp := q.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, buf.Bytes(), 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, q.path); err != nil {
+		return err
+	}
+	q.size = int64(buf.Len())
+	return nil
+}
+
+// transport returns a transport for a Sentry client which sends each event
+// synchronously, adding it to the queue if sending fails. Failures are
+// counted by the metrics.
+func (q *retryQueue) transport(metrics Metrics) *retryTransport {
+	return &retryTransport{queue: q, metrics: metrics}
+}
+
+// retryTransport sends events in envelopes, as the transports of the Sentry
+// client do, but reports failures so that they can be queued.
+type retryTransport struct {
+	queue   *retryQueue
+	metrics Metrics
+	opts    sentry.ClientOptions
+}
+
+func (t *retryTransport) Configure(opts sentry.ClientOptions) {
+	t.opts = opts
+}
+
+func (t *retryTransport) SendEvent(event *sentry.Event) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		sentry.Logger.Printf("Failed to encode event: %v", err)
+		return
+	}
+	entry := retryEntry{Dsn: t.opts.Dsn, Type: "event", Event: payload}
+	if event.Type == "transaction" {
+		entry.Type = event.Type
+	}
+
+	if err := t.send(entry); err != nil {
+		t.metrics.Failed()
+		if !retryable(err) {
+			sentry.Logger.Printf("Failed to send event: %v", err)
+			return
+		}
+		line, _ := json.Marshal(entry)
+		if err := t.queue.push(line); err != nil {
+			sentry.Logger.Printf("Failed to queue event for retry: %v", err)
+		}
+		return
+	}
+	// Sentry is reachable again, so send any events which failed before.
+	if err := t.queue.drain(t.send); err != nil {
+		sentry.Logger.Printf("Failed to update the retry queue: %v", err)
+	}
+}
+
+// send sends the queued entry to its DSN.
+func (t *retryTransport) send(e retryEntry) error {
+	var header struct {
+		EventID sentry.EventID `json:"event_id"`
+	}
+	json.Unmarshal(e.Event, &header)
+	opts := t.opts
+	opts.Dsn = e.Dsn
+	return sendEnvelopeItem(opts, map[string]interface{}{"event_id": header.EventID},
+		map[string]interface{}{"type": e.Type}, e.Event)
+}
+
+// Flush returns immediately, since events are sent synchronously.
+func (t *retryTransport) Flush(timeout time.Duration) bool {
+	return true
+}
+
+// retryable returns whether the send may succeed if retried later: if the
+// server couldn't be reached, was unavailable, or was rate limiting.
+func retryable(err error) bool {
+	var status *statusError
+	if !errors.As(err, &status) {
+		return true
+	}
+	return status.code >= http.StatusInternalServerError || status.code == http.StatusTooManyRequests
+}
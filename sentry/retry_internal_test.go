@@ -0,0 +1,129 @@
+package sentry
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryQueueAppends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "retry.jsonl")
+	q := newRetryQueue(path, 0)
+
+	require.NoError(t, q.push([]byte(`{"dsn":"a","event":{}}`)))
+	before, err := os.Stat(path)
+	require.NoError(t, err)
+	require.NoError(t, q.push([]byte(`{"dsn":"b","event":{}}`)))
+	after, err := os.Stat(path)
+	require.NoError(t, err)
+
+	assert.True(t, os.SameFile(before, after), "the file isn't rewritten")
+	b, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, `{"dsn":"a","event":{}}`+"\n"+`{"dsn":"b","event":{}}`+"\n", string(b))
+}
+
+func TestRetryQueueBackground(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "retry.jsonl")
+	q := newRetryQueue(path, 0)
+	q.interval = 10 * time.Millisecond
+	require.NoError(t, q.push([]byte(`{"dsn":"a","event":{}}`)))
+	require.NoError(t, q.push([]byte(`{"dsn":"b","event":{}}`)))
+
+	var mu sync.Mutex
+	var sent []string
+	down := true
+	q.start(func(e retryEntry) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if down {
+			return errors.New("connection refused")
+		}
+		sent = append(sent, e.Dsn)
+		return nil
+	})
+
+	time.Sleep(50 * time.Millisecond)
+	assert.FileExists(t, path, "kept while Sentry can't be reached")
+
+	mu.Lock()
+	down = false
+	mu.Unlock()
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(sent) == 2
+	}, time.Second, 10*time.Millisecond, "sent without a new event")
+	q.stop()
+
+	assert.Equal(t, []string{"a", "b"}, sent)
+	assert.NoFileExists(t, path)
+}
+
+func TestRetryQueueDrainUnlocked(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "retry.jsonl")
+	q := newRetryQueue(path, 0)
+	require.NoError(t, q.push([]byte(`{"dsn":"a","event":{}}`)))
+
+	sending := make(chan struct{})
+	release := make(chan struct{})
+	drained := make(chan error)
+	go func() {
+		drained <- q.drain(func(e retryEntry) error {
+			close(sending)
+			<-release
+			return errors.New("connection refused")
+		})
+	}()
+
+	// Events are queued while the queue is being sent.
+	<-sending
+	pushed := make(chan error)
+	go func() { pushed <- q.push([]byte(`{"dsn":"b","event":{}}`)) }()
+	select {
+	case err := <-pushed:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("push blocked by a drain in progress")
+	}
+
+	close(release)
+	require.NoError(t, <-drained)
+	b, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, `{"dsn":"a","event":{}}`+"\n"+`{"dsn":"b","event":{}}`+"\n", string(b),
+		"the entry which failed to send is queued again before those pushed since")
+}
+
+func TestRetryQueueDrainKeepsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "retry.jsonl")
+	q := newRetryQueue(path, 0)
+	require.NoError(t, q.push([]byte(`{"dsn":"a","event":{}}`)))
+	require.NoError(t, q.push([]byte(`{"dsn":"b","event":{}}`)))
+
+	// The entries stay in the file while they are sent, so that they aren't
+	// lost if the process exits.
+	var sent []string
+	require.NoError(t, q.drain(func(e retryEntry) error {
+		b, err := ioutil.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, `{"dsn":"a","event":{}}`+"\n"+`{"dsn":"b","event":{}}`+"\n", string(b))
+		sent = append(sent, e.Dsn)
+		return nil
+	}))
+	assert.Equal(t, []string{"a", "b"}, sent)
+	assert.NoFileExists(t, path, "removed once every entry was sent")
+
+	// Another drain doesn't send the entries again.
+	require.NoError(t, q.drain(func(e retryEntry) error {
+		t.Errorf("sent %s again", e.Dsn)
+		return nil
+	}))
+}
@@ -0,0 +1,124 @@
+package sentry_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	sentrygo "github.com/getsentry/sentry-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yext/glog"
+	"github.com/yext/glog-contrib/sentry"
+)
+
+// sentryServer records the messages of the events sent to it in envelopes,
+// failing with a 503 while it is down.
+type sentryServer struct {
+	mu       sync.Mutex
+	down     bool
+	messages []string
+}
+
+func (s *sentryServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.down {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	// The event is the last line of the envelope.
+	var event sentrygo.Event
+	var last []byte
+	scanner := bufio.NewScanner(r.Body)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		last = append(last[:0], scanner.Bytes()...)
+	}
+	if err := json.Unmarshal(last, &event); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	s.messages = append(s.messages, event.Message)
+}
+
+func (s *sentryServer) setDown(down bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.down = down
+}
+
+func (s *sentryServer) Messages() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.messages...)
+}
+
+func TestRetryQueue(t *testing.T) {
+	server := &sentryServer{down: true}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+	dsn := strings.Replace(ts.URL, "http://", "http://public@", 1) + "/1"
+	path := filepath.Join(t.TempDir(), "retry.jsonl")
+
+	metrics := &countingMetrics{}
+	capture := func(msg string) {
+		comm := make(chan glog.Event, 1)
+		comm <- glog.Event{Severity: "ERROR", Message: []byte(msg)}
+		close(comm)
+		require.NoError(t, sentry.CaptureErrorsE("example", []string{dsn}, sentrygo.ClientOptions{}, comm,
+			sentry.WithRetryQueue(path, 0), sentry.WithMetrics(metrics)))
+	}
+
+	// Events which fail to send are queued, even across restarts.
+	capture("during outage")
+	require.FileExists(t, path)
+	assert.Empty(t, server.Messages())
+	assert.Equal(t, 1, metrics.failed)
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"dsn": "truncated` + "\n")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	// Once an event is sent, the queued events follow it.
+	server.setDown(false)
+	capture("after outage")
+	assert.Equal(t, []string{"after outage", "during outage"}, server.Messages(), "corrupt entries are skipped")
+	assert.NoFileExists(t, path, "the queue is emptied")
+}
+
+func TestRetryQueueBounded(t *testing.T) {
+	server := &sentryServer{down: true}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+	dsn := strings.Replace(ts.URL, "http://", "http://public@", 1) + "/1"
+	path := filepath.Join(t.TempDir(), "retry.jsonl")
+
+	capture := func(maxBytes int64, msgs ...string) {
+		comm := make(chan glog.Event, len(msgs))
+		for _, msg := range msgs {
+			comm <- glog.Event{Severity: "ERROR", Message: []byte(msg)}
+		}
+		close(comm)
+		require.NoError(t, sentry.CaptureErrorsE("example", []string{dsn}, sentrygo.ClientOptions{}, comm,
+			sentry.WithRetryQueue(path, maxBytes)))
+	}
+
+	capture(0, "first")
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	// Leave room for two events of about the same size.
+	capture(info.Size()*5/2, "second", "third")
+
+	server.setDown(false)
+	capture(0, "fourth")
+	assert.Equal(t, []string{"fourth", "second", "third"}, server.Messages(), "the oldest events are discarded")
+}
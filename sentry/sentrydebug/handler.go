@@ -0,0 +1,105 @@
+// The sentrydebug package provides a HTTP handler for tuning how glog events
+// are converted to Sentry events, such as their grouping and redaction,
+// without waiting for a real error. It is optional, and must be registered
+// explicitly, such as on an internal debug server:
+//
+//	http.Handle("/debug/sentry", sentrydebug.Handler())
+package sentrydebug
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"runtime"
+	"strings"
+
+	"github.com/yext/glog"
+	"github.com/yext/glog-contrib/sentry"
+)
+
+// Handler returns a handler which converts a sample glog event, given by the
+// form values of the request, with sentry.FromGlogEvent, and responds with the
+// resulting Sentry event encoded as JSON. The event is not sent. The form
+// values are:
+//
+//	message      the message logged
+//	severity     the severity of the event, which defaults to ERROR
+//	error        the message of an error passed to glog (may be repeated)
+//	fingerprint  an entry of the sentry.Fingerprint (may be repeated)
+//	tag          a key=value sentry.Tags entry (may be repeated)
+//	data         a key=value entry of the glog.Data (may be repeated)
+//	environment  the sentry.Environment of the event
+func Handler() http.Handler {
+	return http.HandlerFunc(serve)
+}
+
+func serve(w http.ResponseWriter, r *http.Request) {
+	e, err := parseEvent(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s, _ := sentry.FromGlogEvent(e)
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(s)
+}
+
+// parseEvent builds a glog event from the form values of the request, with
+// the stack trace of the caller.
+func parseEvent(r *http.Request) (glog.Event, error) {
+	if err := r.ParseForm(); err != nil {
+		return glog.Event{}, err
+	}
+
+	callers := make([]uintptr, 20)
+	written := runtime.Callers(2, callers)
+	e := glog.Event{
+		Severity:   strings.ToUpper(r.Form.Get("severity")),
+		Message:    []byte(r.Form.Get("message")),
+		StackTrace: callers[:written],
+	}
+	if e.Severity == "" {
+		e.Severity = "ERROR"
+	}
+
+	for _, msg := range r.Form["error"] {
+		e.Data = append(e.Data, glog.ErrorArg{Error: errors.New(msg)})
+	}
+	if fp := r.Form["fingerprint"]; len(fp) > 0 {
+		e.Data = append(e.Data, sentry.Fingerprint(fp...))
+	}
+	if tags, err := parsePairs(r.Form["tag"]); err != nil {
+		return glog.Event{}, err
+	} else if len(tags) > 0 {
+		e.Data = append(e.Data, sentry.Tags(tags))
+	}
+	if data, err := parsePairs(r.Form["data"]); err != nil {
+		return glog.Event{}, err
+	} else if len(data) > 0 {
+		m := map[string]interface{}{}
+		for k, v := range data {
+			m[k] = v
+		}
+		e.Data = append(e.Data, m)
+	}
+	if env := r.Form.Get("environment"); env != "" {
+		e.Data = append(e.Data, sentry.Environment(env))
+	}
+	return e, nil
+}
+
+// parsePairs parses values in the format key=value.
+func parsePairs(values []string) (map[string]string, error) {
+	m := map[string]string{}
+	for _, v := range values {
+		i := strings.Index(v, "=")
+		if i <= 0 {
+			return nil, errors.New("sentrydebug: expected key=value, got " + v)
+		}
+		m[v[:i]] = v[i+1:]
+	}
+	return m, nil
+}
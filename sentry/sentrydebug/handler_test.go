@@ -0,0 +1,54 @@
+package sentrydebug_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	sentrygo "github.com/getsentry/sentry-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yext/glog-contrib/sentry/sentrydebug"
+)
+
+func TestHandler(t *testing.T) {
+	form := url.Values{
+		"message":     {"failed to load"},
+		"error":       {"connection refused"},
+		"fingerprint": {"load", "db"},
+		"tag":         {"tenant=acme"},
+		"data":        {"id=123"},
+		"environment": {"staging"},
+	}
+	req := httptest.NewRequest("POST", "/debug/sentry", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	sentrydebug.Handler().ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	var e sentrygo.Event
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &e))
+	assert.Equal(t, sentrygo.LevelError, e.Level)
+	assert.Contains(t, e.Message, "failed to load")
+	assert.Equal(t, []string{"load", "db"}, e.Fingerprint)
+	assert.Equal(t, "acme", e.Tags["tenant"])
+	assert.Equal(t, "staging", e.Environment)
+	assert.Equal(t, map[string]interface{}{"id": "123"}, e.Extra["Data"])
+	require.NotEmpty(t, e.Exception)
+	var types []string
+	for _, ex := range e.Exception {
+		types = append(types, ex.Type)
+	}
+	assert.Contains(t, types, "connection refused")
+}
+
+func TestHandlerInvalidPair(t *testing.T) {
+	req := httptest.NewRequest("GET", "/debug/sentry?message=test&tag=invalid", nil)
+	w := httptest.NewRecorder()
+	sentrydebug.Handler().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
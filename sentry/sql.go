@@ -0,0 +1,56 @@
+package sentry
+
+import (
+	"reflect"
+	"strconv"
+)
+
+// The tag set on events whose error chain contains a SQL driver error.
+const dbErrorCodeTag = "db_error_code"
+
+// The SQL driver errors which are recognised, by package path and type name,
+// with the field holding their error code.
+var dbErrorTypes = map[string]string{
+	"github.com/lib/pq.Error":                   "Code",
+	"github.com/jackc/pgconn.PgError":           "Code",
+	"github.com/jackc/pgx/v5/pgconn.PgError":    "Code",
+	"github.com/go-sql-driver/mysql.MySQLError": "Number",
+}
+
+// dbErrorCode extracts the error code and message of a SQL driver error:
+// the SQLSTATE of a *pq.Error or *pgconn.PgError, which have string Code and
+// Message fields, or the error number of a *mysql.MySQLError, which has a
+// numeric Number field and a Message field. Errors are only recognised by the
+// package path and name of their type, and their fields are read by
+// reflection, so that no SQL drivers are dependencies.
+func dbErrorCode(err error) (code string, msg string, ok bool) {
+	v := reflect.ValueOf(err)
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return "", "", false
+	}
+	codeField, ok := dbErrorTypes[v.Type().PkgPath()+"."+v.Type().Name()]
+	if !ok {
+		return "", "", false
+	}
+
+	message, ok := fieldByName(v, "Message")
+	if !ok || message.Kind() != reflect.String {
+		return "", "", false
+	}
+	c, ok := fieldByName(v, codeField)
+	if !ok {
+		return "", "", false
+	}
+	switch c.Kind() {
+	case reflect.String:
+		if c.String() != "" {
+			return c.String(), message.String(), true
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(c.Uint(), 10), message.String(), true
+	}
+	return "", "", false
+}
@@ -0,0 +1,75 @@
+package sentry
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yext/glog"
+)
+
+// The following types mirror the shape of the errors of the lib/pq and
+// go-sql-driver/mysql packages, and are registered as driver errors by
+// registerDBErrors.
+
+type ErrorCode string
+
+type Error struct {
+	Severity string
+	Code     ErrorCode
+	Message  string
+}
+
+func (e *Error) Error() string { return "pq: " + e.Message }
+
+type MySQLError struct {
+	Number   uint16
+	SQLState [5]byte
+	Message  string
+}
+
+func (e *MySQLError) Error() string { return fmt.Sprintf("Error %d: %s", e.Number, e.Message) }
+
+// registerDBErrors recognises Error and MySQLError as SQL driver errors for
+// the duration of the test.
+func registerDBErrors(t *testing.T) {
+	const pkg = "github.com/yext/glog-contrib/sentry."
+	dbErrorTypes[pkg+"Error"] = "Code"
+	dbErrorTypes[pkg+"MySQLError"] = "Number"
+	t.Cleanup(func() {
+		delete(dbErrorTypes, pkg+"Error")
+		delete(dbErrorTypes, pkg+"MySQLError")
+	})
+}
+
+func TestDBErrorCode(t *testing.T) {
+	registerDBErrors(t)
+	for _, tc := range []struct {
+		err      error
+		code     string
+		msgValue string
+	}{
+		{&Error{Code: "23505", Message: `duplicate key value violates unique constraint "users_pkey"`}, "23505",
+			`duplicate key value violates unique constraint "users_pkey"`},
+		{&MySQLError{Number: 1062, Message: "Duplicate entry '42' for key 'PRIMARY'"}, "1062",
+			"Duplicate entry '42' for key 'PRIMARY'"},
+	} {
+		err := fmt.Errorf("insert failed: %w", tc.err)
+		e, _ := FromGlogEvent(glog.Event{
+			Severity: "ERROR",
+			Message:  []byte(err.Error()),
+			Data:     []interface{}{glog.ErrorArg{Error: err}},
+		})
+
+		assert.Equal(t, tc.code, e.Tags["db_error_code"])
+		ex := e.Exception[1] // innermost exception is the driver error itself
+		assert.Equal(t, "db error: "+tc.code, ex.Type, "grouped by the error code")
+		assert.Contains(t, ex.Value, tc.msgValue)
+	}
+}
+
+func TestDBErrorCodeWithoutCode(t *testing.T) {
+	registerDBErrors(t)
+	_, _, ok := dbErrorCode(&Error{Message: "no code"})
+	assert.False(t, ok)
+}
@@ -0,0 +1,24 @@
+package sentry_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yext/glog"
+	"github.com/yext/glog-contrib/sentry"
+)
+
+// Error has the shape of a *pq.Error, but isn't from a SQL driver.
+type Error struct {
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string { return e.Message }
+
+func TestDBErrorCodeOtherErrors(t *testing.T) {
+	err := fmt.Errorf("insert failed: %w", &Error{Code: "23505", Message: "duplicate key"})
+	e, _ := sentry.FromGlogEvent(newErrorEvent(err.Error(), glog.ErrorArg{Error: err}))
+	assert.NotContains(t, e.Tags, "db_error_code", "only errors from known SQL drivers are recognised")
+}
@@ -0,0 +1,49 @@
+package sentry
+
+import (
+	"sort"
+	"sync"
+)
+
+var (
+	tenantDsnsMu sync.RWMutex
+	tenantDsns   map[string]string
+)
+
+// SetTenantDsns sets the dedicated DSNs of tenants, by their IDs, to which
+// issues with a Tenant attribute are sent. Issues for other tenants are sent
+// to the primary DSN. It must be called before CaptureErrors, which creates a
+// client for each of the DSNs in addition to those passed to it.
+func SetTenantDsns(dsns map[string]string) {
+	tenantDsnsMu.Lock()
+	defer tenantDsnsMu.Unlock()
+	tenantDsns = dsns
+}
+
+// tenantDsn returns the dedicated DSN of the tenant, if it has one.
+func tenantDsn(id string) string {
+	tenantDsnsMu.RLock()
+	defer tenantDsnsMu.RUnlock()
+	return tenantDsns[id]
+}
+
+// withTenantDsns returns the DSNs, followed by the dedicated DSNs of tenants
+// which aren't among them.
+func withTenantDsns(dsns []string) []string {
+	tenantDsnsMu.RLock()
+	defer tenantDsnsMu.RUnlock()
+
+	seen := make(map[string]bool, len(dsns))
+	for _, dsn := range dsns {
+		seen[dsn] = true
+	}
+	var extra []string
+	for _, dsn := range tenantDsns {
+		if !seen[dsn] {
+			seen[dsn] = true
+			extra = append(extra, dsn)
+		}
+	}
+	sort.Strings(extra)
+	return append(dsns[:len(dsns):len(dsns)], extra...)
+}
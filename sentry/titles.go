@@ -0,0 +1,75 @@
+package sentry
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// TitleTransformer rewrites the title of an issue (the type of each of its
+// exceptions), such as to remove unique identifiers which would otherwise
+// prevent similar issues from being grouped together.
+type TitleTransformer func(title string) string
+
+var (
+	titleTransformersMu sync.RWMutex
+	titleTransformers   []TitleTransformer
+)
+
+// SetTitleTransformers sets the pipeline of transformers applied in order to
+// the title of each issue, after the default sanitization of messages and
+// format strings. By default, titles are not transformed.
+//
+//	sentry.SetTitleTransformers(sentry.StripUUIDs, sentry.CollapseDigits, sentry.NormalizeWhitespace)
+func SetTitleTransformers(transformers ...TitleTransformer) {
+	titleTransformersMu.Lock()
+	defer titleTransformersMu.Unlock()
+	titleTransformers = transformers
+}
+
+// transformTitle applies the title transformers to the title.
+func transformTitle(title string) string {
+	titleTransformersMu.RLock()
+	transformers := titleTransformers
+	titleTransformersMu.RUnlock()
+
+	for _, t := range transformers {
+		title = t(title)
+	}
+	return title
+}
+
+// normalizeTitle applies the title transformers to the title, or the built-in
+// transformers if none are set, to remove any unique identifiers from it.
+func normalizeTitle(title string) string {
+	titleTransformersMu.RLock()
+	transformers := titleTransformers
+	titleTransformersMu.RUnlock()
+
+	if len(transformers) == 0 {
+		return NormalizeWhitespace(CollapseDigits(StripUUIDs(title)))
+	}
+	return transformTitle(title)
+}
+
+var (
+	uuidRe  = regexp.MustCompile(`(?i)\b[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}\b`)
+	digitRe = regexp.MustCompile(`[0-9]+`)
+)
+
+// StripUUIDs is a TitleTransformer which removes UUIDs from the title.
+func StripUUIDs(title string) string {
+	return uuidRe.ReplaceAllString(title, "")
+}
+
+// CollapseDigits is a TitleTransformer which replaces each run of digits in
+// the title with a single "#".
+func CollapseDigits(title string) string {
+	return digitRe.ReplaceAllString(title, "#")
+}
+
+// NormalizeWhitespace is a TitleTransformer which replaces each run of
+// whitespace in the title with a single space, and trims it from either end.
+func NormalizeWhitespace(title string) string {
+	return strings.Join(strings.Fields(title), " ")
+}
@@ -0,0 +1,69 @@
+package sentry_test
+
+import (
+	"errors"
+	"flag"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yext/glog"
+	"github.com/yext/glog-contrib/sentry"
+)
+
+func TestStripUUIDs(t *testing.T) {
+	assert.Equal(t, "order  not found", sentry.StripUUIDs("order 3F2504E0-4F89-11D3-9A0C-0305E82C3301 not found"))
+	assert.Equal(t, "order 1234 not found", sentry.StripUUIDs("order 1234 not found"))
+}
+
+func TestCollapseDigits(t *testing.T) {
+	assert.Equal(t, "shard # timed out after #ms", sentry.CollapseDigits("shard 12 timed out after 3500ms"))
+	assert.Equal(t, "no digits", sentry.CollapseDigits("no digits"))
+}
+
+func TestNormalizeWhitespace(t *testing.T) {
+	assert.Equal(t, "a b c", sentry.NormalizeWhitespace("  a \t b\n\nc "))
+}
+
+func TestSetTitleTransformers(t *testing.T) {
+	msg := "shard 12 failed for 3f2504e0-4f89-11d3-9a0c-0305e82c3301  request"
+	e, _ := sentry.FromGlogEvent(newErrorEvent(msg))
+	require.NotEmpty(t, e.Exception)
+	assert.Equal(t, msg, e.Exception[0].Type, "titles are not transformed by default")
+
+	sentry.SetTitleTransformers(sentry.StripUUIDs, sentry.CollapseDigits, sentry.NormalizeWhitespace)
+	defer sentry.SetTitleTransformers()
+
+	e, _ = sentry.FromGlogEvent(newErrorEvent(msg))
+	require.NotEmpty(t, e.Exception)
+	assert.Equal(t, "shard # failed for request", e.Exception[0].Type, "transformers are applied in order")
+
+	sentry.SetTitleTransformers(sentry.CollapseDigits, sentry.StripUUIDs)
+	e, _ = sentry.FromGlogEvent(newErrorEvent(msg))
+	require.NotEmpty(t, e.Exception)
+	assert.Contains(t, e.Exception[0].Type, "#f#e#-", "UUIDs are no longer recognized after collapsing digits")
+}
+
+func TestMessageFingerprinting(t *testing.T) {
+	framelessEvent := func(msg string) glog.Event {
+		return glog.Event{
+			Severity: "ERROR",
+			Message:  []byte(msg),
+			Data:     []interface{}{glog.ErrorArg{Error: errors.New(msg)}},
+		}
+	}
+
+	e, _ := sentry.FromGlogEvent(framelessEvent("lookup of user 1234 failed"))
+	assert.Empty(t, e.Fingerprint, "not grouped by message by default")
+
+	flag.Set("sentryMessageFingerprinting", "true")
+	defer flag.Set("sentryMessageFingerprinting", "false")
+
+	a, _ := sentry.FromGlogEvent(framelessEvent("lookup of user 1234 failed"))
+	b, _ := sentry.FromGlogEvent(framelessEvent("lookup of user 5678 failed"))
+	assert.Equal(t, []string{"message", "lookup of user # failed"}, a.Fingerprint)
+	assert.Equal(t, a.Fingerprint, b.Fingerprint, "frameless events with different IDs are grouped")
+
+	e, _ = sentry.FromGlogEvent(newErrorEvent("lookup of user 1234 failed"))
+	assert.Empty(t, e.Fingerprint, "events with in-app frames are not grouped by message")
+}
@@ -0,0 +1,138 @@
+package sentry
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+)
+
+// The number of events the transport buffers, as for the transport of the
+// Sentry client.
+const (
+	transportBufferSize        = 30
+	tracingTransportBufferSize = 1000
+)
+
+// asyncTransport sends events from a background goroutine, as the
+// HTTPTransport of the Sentry client does, with an HTTPSyncTransport so that
+// the events are sent and rate limited just as the Sentry client would. Unlike
+// HTTPTransport, its goroutine stops when it is closed, so that capturing may
+// be restarted without leaking it.
+type asyncTransport struct {
+	transport *sentry.HTTPSyncTransport
+	events    chan *sentry.Event
+	flushes   chan chan struct{}
+	done      chan struct{}
+	stopped   chan struct{}
+}
+
+func newAsyncTransport(opts sentry.ClientOptions) *asyncTransport {
+	size := transportBufferSize
+	if opts.TracesSampleRate != 0 || opts.TracesSampler != nil {
+		size = tracingTransportBufferSize
+	}
+	t := &asyncTransport{
+		transport: sentry.NewHTTPSyncTransport(),
+		events:    make(chan *sentry.Event, size),
+		flushes:   make(chan chan struct{}),
+		done:      make(chan struct{}),
+		stopped:   make(chan struct{}),
+	}
+	go t.run()
+	return t
+}
+
+// Configure is called by the client with its options, before it sends any
+// events.
+func (t *asyncTransport) Configure(opts sentry.ClientOptions) {
+	t.transport.Configure(opts)
+}
+
+// SendEvent queues the event to be sent, dropping it if the buffer is full or
+// the transport is closed.
+func (t *asyncTransport) SendEvent(event *sentry.Event) {
+	select {
+	case <-t.done:
+		return
+	default:
+	}
+	select {
+	case t.events <- event:
+	default:
+		sentry.Logger.Println("Event dropped due to transport buffer being full.")
+	}
+}
+
+// Flush waits until the events queued before it are sent, blocking for at
+// most the given timeout. It returns false if the timeout was reached.
+func (t *asyncTransport) Flush(timeout time.Duration) bool {
+	flushed := make(chan struct{})
+	toolate := time.After(timeout)
+	select {
+	case t.flushes <- flushed:
+	case <-t.stopped:
+		return false
+	case <-toolate:
+		return false
+	}
+	select {
+	case <-flushed:
+		return true
+	case <-toolate:
+		return false
+	}
+}
+
+// close stops the goroutine, dropping any events which weren't flushed.
+func (t *asyncTransport) close() {
+	close(t.done)
+	<-t.stopped
+}
+
+func (t *asyncTransport) run() {
+	defer close(t.stopped)
+	for {
+		select {
+		case event := <-t.events:
+			t.transport.SendEvent(event)
+		case flushed := <-t.flushes:
+			for sending := true; sending; {
+				select {
+				case event := <-t.events:
+					t.transport.SendEvent(event)
+				default:
+					sending = false
+				}
+			}
+			close(flushed)
+		case <-t.done:
+			return
+		}
+	}
+}
+
+// httpTransport returns the transport used to send to Sentry with the client
+// options, as the Sentry client would create it: with the proxy and CA
+// certificates of the options, or otherwise the proxy of the environment.
+func httpTransport(opts sentry.ClientOptions) *http.Transport {
+	t := &http.Transport{Proxy: http.ProxyFromEnvironment}
+	switch {
+	case opts.HTTPSProxy != "":
+		t.Proxy = fixedProxy(opts.HTTPSProxy)
+	case opts.HTTPProxy != "":
+		t.Proxy = fixedProxy(opts.HTTPProxy)
+	}
+	if opts.CaCerts != nil {
+		t.TLSClientConfig = &tls.Config{RootCAs: opts.CaCerts}
+	}
+	return t
+}
+
+func fixedProxy(proxy string) func(*http.Request) (*url.URL, error) {
+	return func(*http.Request) (*url.URL, error) {
+		return url.Parse(proxy)
+	}
+}
@@ -0,0 +1,58 @@
+package sentry
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/yext/glog"
+)
+
+// The default number of events queued for the workers.
+const defaultWorkerQueue = 1000
+
+// workerPool captures glog events on a fixed number of goroutines, so that
+// slow sends to Sentry don't block the channel of the glog backend.
+type workerPool struct {
+	queue   chan glog.Event
+	wg      sync.WaitGroup
+	dropped uint64
+}
+
+func newWorkerPool(workers, queue int, capture func(glog.Event)) *workerPool {
+	if queue <= 0 {
+		queue = defaultWorkerQueue
+	}
+	w := &workerPool{queue: make(chan glog.Event, queue)}
+	for i := 0; i < workers; i++ {
+		w.wg.Add(1)
+		go func() {
+			defer w.wg.Done()
+			for e := range w.queue {
+				capture(e)
+			}
+		}()
+	}
+	return w
+}
+
+// offer queues the event without blocking, dropping it if the queue is full.
+// It returns whether the event was queued.
+func (w *workerPool) offer(e glog.Event) bool {
+	select {
+	case w.queue <- e:
+		return true
+	default:
+		atomic.AddUint64(&w.dropped, 1)
+		return false
+	}
+}
+
+// close captures any queued events and stops the workers.
+func (w *workerPool) close() {
+	close(w.queue)
+	w.wg.Wait()
+	if dropped := atomic.LoadUint64(&w.dropped); dropped > 0 {
+		sentry.Logger.Printf("Dropped %d events exceeding the worker queue", dropped)
+	}
+}
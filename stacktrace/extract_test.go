@@ -0,0 +1,201 @@
+package stacktrace_test
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yext/yerrors"
+	"golang.org/x/xerrors"
+
+	"github.com/yext/glog-contrib/stacktrace"
+)
+
+// The tests in this file check the frames extracted from the errors of each
+// supported library against the lines the errors were created at. Since only
+// xerrors and yerrors are dependencies of this module, the errors of
+// github.com/pkg/errors, github.com/go-errors/errors and
+// github.com/pingcap/errors are stood in for by types with the same methods.
+
+// line returns the line of its caller.
+func line() int {
+	_, _, l, _ := runtime.Caller(1)
+	return l
+}
+
+// callers returns the program counters of the stack of the caller of its
+// caller, as the error libraries record them.
+func callers() []uintptr {
+	pcs := make([]uintptr, 50)
+	return pcs[:runtime.Callers(3, pcs)]
+}
+
+// pkgError has the StackTrace method of github.com/pkg/errors.
+type pkgError struct{ pcs []uintptr }
+
+type pkgFrame uintptr
+type pkgStackTrace []pkgFrame
+
+func (e *pkgError) Error() string { return "pkg error" }
+func (e *pkgError) StackTrace() pkgStackTrace {
+	st := make(pkgStackTrace, len(e.pcs))
+	for i, pc := range e.pcs {
+		st[i] = pkgFrame(pc)
+	}
+	return st
+}
+
+func newPkgError() *pkgError { return &pkgError{callers()} }
+
+// goError has the StackFrames method of github.com/go-errors/errors.
+type goError struct{ pcs []uintptr }
+
+type goStackFrame struct {
+	File           string
+	LineNumber     int
+	Name           string
+	Package        string
+	ProgramCounter uintptr
+}
+
+func (e *goError) Error() string { return "go error" }
+func (e *goError) StackFrames() []goStackFrame {
+	frames := make([]goStackFrame, len(e.pcs))
+	for i, pc := range e.pcs {
+		frames[i] = goStackFrame{ProgramCounter: pc}
+	}
+	return frames
+}
+
+func (e *goError) Callers() []uintptr { return e.pcs }
+
+func newGoError() *goError { return &goError{callers()} }
+
+// pcsError returns its program counters directly.
+type pcsError struct{ pcs []uintptr }
+
+func (e *pcsError) Error() string         { return "pcs error" }
+func (e *pcsError) StackTrace() []uintptr { return e.pcs }
+
+func newPcsError() *pcsError { return &pcsError{callers()} }
+
+// argsError has a StackTrace method which can't be called without arguments.
+type argsError struct{}
+
+func (e argsError) Error() string                 { return "args error" }
+func (e argsError) StackTrace(skip int) []uintptr { return nil }
+
+// pingcapError has the GetStackTracer method of github.com/pingcap/errors.
+type pingcapError struct{ stack *pkgError }
+
+type pingcapStackTracer interface {
+	StackTrace() pkgStackTrace
+}
+
+func (e *pingcapError) Error() string                      { return "pingcap error" }
+func (e *pingcapError) GetStackTracer() pingcapStackTracer { return e.stack }
+
+func newPingcapError() *pingcapError { return &pingcapError{&pkgError{callers()}} }
+
+func createXerror() (error, []int) {
+	return xerrors.New("failed"), []int{line()}
+}
+
+func wrapXerror() (error, []int) {
+	err, lines := createXerror()
+	return xerrors.Errorf("wrapped: %w", err), append([]int{line()}, lines...)
+}
+
+func createYerror() (error, []int) {
+	return yerrors.New("failed"), []int{line()}
+}
+
+func wrapYerror() (error, []int) {
+	err, lines := createYerror()
+	return yerrors.Wrap(err), append([]int{line()}, lines...)
+}
+
+func createPkgError() (error, []int) {
+	return newPkgError(), []int{line()}
+}
+
+func createGoError() (error, []int) {
+	return newGoError(), []int{line()}
+}
+
+func createPingcapError() (error, []int) {
+	return newPingcapError(), []int{line()}
+}
+
+func createPcsError() (error, []int) {
+	return newPcsError(), []int{line()}
+}
+
+// goldenFrame is a frame expected from this file, at the line of the error
+// created by the call at the given index (from the outermost call).
+type goldenFrame struct {
+	function string
+	call     int
+}
+
+func TestExtractStacktraceGolden(t *testing.T) {
+	const pkg = "github.com/yext/glog-contrib/stacktrace_test."
+	for _, tc := range []struct {
+		name   string
+		create func() (error, []int)
+		// The frames from this file, other than the test, from the oldest
+		// call. The frame of each error from xerrors or yerrors is included
+		// both from its program counters and from its detail, which names
+		// the package of the function.
+		golden []goldenFrame
+	}{
+		{"xerrors", createXerror, []goldenFrame{{"createXerror", 0}, {pkg + "createXerror", 0}}},
+		{"xerrors wrapped", wrapXerror, []goldenFrame{{"wrapXerror", 0}, {pkg + "wrapXerror", 0}, {pkg + "createXerror", 1}}},
+		{"yerrors", createYerror, []goldenFrame{{"createYerror", 0}, {pkg + "createYerror", 0}}},
+		{"yerrors wrapped", wrapYerror, []goldenFrame{{"wrapYerror", 0}, {pkg + "wrapYerror", 0}, {pkg + "createYerror", 1}}},
+		{"pkg/errors", createPkgError, []goldenFrame{{"createPkgError", 0}}},
+		{"go-errors", createGoError, []goldenFrame{{"createGoError", 0}}},
+		{"pingcap/errors", createPingcapError, []goldenFrame{{"createPingcapError", 0}}},
+		{"StackTrace() []uintptr", createPcsError, []goldenFrame{{"createPcsError", 0}}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err, lines := tc.create()
+			trace := stacktrace.ExtractStacktrace(err)
+			require.NotNil(t, trace)
+
+			var frames []string
+			for _, f := range trace.Frames {
+				if filepath.Base(f.AbsPath) == "extract_test.go" && !strings.Contains(f.Function, "TestExtractStacktraceGolden") {
+					frames = append(frames, fmt.Sprintf("%s:%d", f.Function, f.Lineno))
+					assert.True(t, f.InApp, "frames from this file are in-app")
+				}
+			}
+
+			var golden []string
+			for _, g := range tc.golden {
+				golden = append(golden, fmt.Sprintf("%s:%d", g.function, lines[g.call]))
+			}
+			assert.Equal(t, golden, frames)
+		})
+	}
+}
+
+func TestExtractStacktraceWithoutStack(t *testing.T) {
+	for name, err := range map[string]error{
+		"nil":                nil,
+		"errors.New":         errors.New("failed"),
+		"arguments":          argsError{},
+		"nil pointer":        (*pkgError)(nil),
+		"nil GetStackTracer": &pingcapError{},
+		"empty StackTrace":   &pcsError{},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Nil(t, stacktrace.ExtractStacktrace(err))
+		})
+	}
+}
@@ -1,17 +1,30 @@
 package stacktrace
 
 import (
+	"go/build"
+	"io/ioutil"
 	"os"
 	"path"
 	"path/filepath"
+	"runtime/debug"
 	"strings"
+	"sync"
 )
 
 // GopathRelativeFile sanitizes the path to remove GOPATH and obtain the import path.
 // Concretely, this takes the path after the last instance of '/src/'.
 // This may omit some of the path if there is an src directory in a package import path.
 // If there are no /src/ directories in the path, the base filename is returned.
+// For files within Bazel runfiles, the path relative to the workspace is returned.
 func GopathRelativeFile(absPath string) string {
+	// Files within Bazel runfiles are relative to the workspace
+	if rel, ok := runfilesRelativePath(absPath); ok {
+		if slash := strings.Index(rel, "/"); slash != -1 {
+			return rel[slash+1:]
+		}
+		return rel
+	}
+
 	candidates := strings.SplitAfter(absPath, "/src/")
 	if len(candidates) > 0 {
 		return candidates[len(candidates)-1]
@@ -21,25 +34,154 @@ func GopathRelativeFile(absPath string) string {
 
 // GuessAbsPath guesses the proper absolute path if it is not
 // provided, making a best-effort guess so that Sentry can attempt
-// to augment the error with source code
+// to augment the error with source code. The path is resolved against each
+// directory of the GOPATH (with and without its src directory), the module
+// cache, and the directory of the main module, and the first file which
+// exists is returned. If none exist, it is resolved against the first
+// directory of the GOPATH.
 func GuessAbsPath(f string) string {
-	gopath := os.Getenv("GOPATH")
-	// Break out if the GOPATH can't be identified, or the path
-	// is already an absolute path.
-	if gopath == "" || strings.HasPrefix(f, "/") {
+	// Relative paths within Bazel runfiles are resolved against the
+	// runfiles directory of the running binary, if it is known.
+	if rel, ok := runfilesRelativePath(f); ok && !strings.HasPrefix(f, "/") {
+		if dir := os.Getenv("RUNFILES_DIR"); dir != "" {
+			return path.Join(dir, rel)
+		}
 		return f
 	}
 
-	ignoredPrefixes := []string{gopath, "external/", "GOROOT/", "bazel-"}
+	if strings.HasPrefix(f, "/") {
+		return f
+	}
+	gopaths := filepath.SplitList(os.Getenv("GOPATH"))
+	ignoredPrefixes := append([]string{"external/", "GOROOT/", "bazel-"}, gopaths...)
 	for _, prefix := range ignoredPrefixes {
 		if strings.HasPrefix(f, prefix) {
 			return f
 		}
 	}
 
+	for _, candidate := range absPathCandidates(f, gopaths) {
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate
+		}
+	}
+
+	// Break out if the GOPATH can't be identified.
+	if len(gopaths) == 0 {
+		return f
+	}
+	return gopathJoin(gopaths[0], f)
+}
+
+// absPathCandidates returns the paths which the relative path may be
+// resolved to, in order of preference.
+func absPathCandidates(f string, gopaths []string) []string {
+	if len(gopaths) == 0 && build.Default.GOPATH != "" {
+		gopaths = filepath.SplitList(build.Default.GOPATH)
+	}
+	var candidates []string
+	for _, gopath := range gopaths {
+		candidates = append(candidates, gopathJoin(gopath, f), path.Join(gopath, "src", f))
+	}
+
+	// Files of dependencies built with -trimpath are named by their module
+	// path and version, relative to the module cache.
+	modcache := os.Getenv("GOMODCACHE")
+	if modcache == "" && len(gopaths) > 0 {
+		modcache = path.Join(gopaths[0], "pkg", "mod")
+	}
+	if modcache != "" && strings.Contains(f, "@") {
+		candidates = append(candidates, path.Join(modcache, f))
+	}
+
+	// Files of the main module are named by its module path.
+	if modPath, dir := mainModule(); modPath != "" && strings.HasPrefix(f, modPath+"/") {
+		candidates = append(candidates, path.Join(dir, strings.TrimPrefix(f, modPath+"/")))
+	}
+	return candidates
+}
+
+// gopathJoin joins the path to the GOPATH directory, unless it already begins
+// with the name of the directory.
+func gopathJoin(gopath, f string) string {
 	if strings.HasPrefix(f, filepath.Base(gopath)) {
 		return path.Join(filepath.Dir(gopath), f)
-	} else {
-		return path.Join(gopath, f)
 	}
+	return path.Join(gopath, f)
+}
+
+var (
+	mainModuleOnce sync.Once
+	mainModulePath string
+	mainModuleDir  string
+)
+
+// mainModule returns the path of the main module of the running binary, and
+// the directory containing its go.mod, found by searching upwards from the
+// working directory. Both are empty if they can't be determined.
+func mainModule() (string, string) {
+	mainModuleOnce.Do(func() {
+		info, ok := debug.ReadBuildInfo()
+		if !ok || info.Main.Path == "" {
+			return
+		}
+		dir, err := os.Getwd()
+		if err != nil {
+			return
+		}
+		for {
+			if b, err := ioutil.ReadFile(filepath.Join(dir, "go.mod")); err == nil {
+				if modulePath(b) == info.Main.Path {
+					mainModulePath, mainModuleDir = info.Main.Path, dir
+				}
+				return
+			}
+			parent := filepath.Dir(dir)
+			if parent == dir {
+				return
+			}
+			dir = parent
+		}
+	})
+	return mainModulePath, mainModuleDir
+}
+
+// modulePath returns the module path declared by the contents of a go.mod
+// file, or an empty string if there is none.
+func modulePath(goMod []byte) string {
+	for _, line := range strings.Split(string(goMod), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && fields[0] == "module" {
+			return strings.Trim(fields[1], "\"`")
+		}
+	}
+	return ""
+}
+
+// The directory suffix of a Bazel runfiles tree.
+const runfilesDir = ".runfiles/"
+
+// runfilesRelativePath returns the portion of a path within a Bazel runfiles
+// tree (beginning with the workspace name), if the path is within one.
+func runfilesRelativePath(f string) (string, bool) {
+	if i := strings.LastIndex(f, runfilesDir); i != -1 {
+		return f[i+len(runfilesDir):], true
+	}
+	return "", false
+}
+
+// resolvePaths returns the absolute path and the cleaned up filename of a
+// frame. The frames of repeated stacks are cached by extractFrames, so the
+// paths are only resolved the first time a stack is seen.
+func resolvePaths(absPath, filename string) (string, string) {
+	// Without an absolute filesystem path for AbsPath,
+	// Sentry will not pull in neighboring code segments.
+	if absPath != "" && !strings.HasPrefix(absPath, "/") {
+		absPath = GuessAbsPath(absPath)
+	} else if absPath == "" {
+		absPath = GuessAbsPath(filename)
+	}
+
+	// Clean up the returned filename to remove the gopath
+	return absPath, GopathRelativeFile(filename)
 }
@@ -0,0 +1,22 @@
+package stacktrace
+
+import "testing"
+
+// Frames which are typical of a repeated error, as reported by a binary built
+// with -trimpath.
+var benchmarkPaths = [][2]string{
+	{"", "github.com/yext/app/server/handler.go"},
+	{"", "github.com/yext/app/server/router.go"},
+	{"", "net/http/server.go"},
+	{"/go/src/github.com/yext/app/main.go", "/go/src/github.com/yext/app/main.go"},
+	{"", "external/com_github_yext_glog/glog.go"},
+}
+
+func BenchmarkResolvePaths(b *testing.B) {
+	b.Setenv("GOPATH", "/home/user/go")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		p := benchmarkPaths[i%len(benchmarkPaths)]
+		resolvePaths(p[0], p[1])
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/yext/glog-contrib/stacktrace"
 )
@@ -33,3 +34,52 @@ func TestGuessAbsPath(t *testing.T) {
 	assert.Equal(t, "/path/to/foo/bar.go", stacktrace.GuessAbsPath("/path/to/foo/bar.go"))
 	assert.Equal(t, path.Join(gopath, "foo/bar.go"), stacktrace.GuessAbsPath(path.Join(gopath, "foo/bar.go")))
 }
+
+func TestRunfilesRelativeFile(t *testing.T) {
+	assert.Equal(t, "yext/examples/example.go", stacktrace.GopathRelativeFile("/home/user/.cache/bazel/execroot/__main__/bazel-out/k8-fastbuild/bin/yext/examples/example_/example.runfiles/__main__/yext/examples/example.go"))
+	assert.Equal(t, "yext/examples/example.go", stacktrace.GopathRelativeFile("example.runfiles/com_github_yext/yext/examples/example.go"))
+}
+
+func TestGuessAbsPathRunfiles(t *testing.T) {
+	runfiles := "bazel-out/k8-fastbuild/bin/yext/example_/example.runfiles/__main__/yext/example.go"
+
+	os.Setenv("RUNFILES_DIR", "/runfiles")
+	defer os.Unsetenv("RUNFILES_DIR")
+	assert.Equal(t, "/runfiles/__main__/yext/example.go", stacktrace.GuessAbsPath(runfiles), "resolved against RUNFILES_DIR")
+	assert.Equal(t, "/path/to/example.runfiles/__main__/yext/example.go", stacktrace.GuessAbsPath("/path/to/example.runfiles/__main__/yext/example.go"), "absolute paths are unchanged")
+
+	os.Unsetenv("RUNFILES_DIR")
+	assert.Equal(t, runfiles, stacktrace.GuessAbsPath(runfiles), "unchanged if the runfiles directory is unknown")
+}
+
+// touch creates an empty file at the path, and any directories containing it.
+func touch(t *testing.T, name string) {
+	require.NoError(t, os.MkdirAll(filepath.Dir(name), 0755))
+	require.NoError(t, os.WriteFile(name, nil, 0644))
+}
+
+func TestGuessAbsPathGopathList(t *testing.T) {
+	first, second := t.TempDir(), t.TempDir()
+	touch(t, filepath.Join(second, "src/github.com/yext/app/main.go"))
+	t.Setenv("GOPATH", first+string(filepath.ListSeparator)+second)
+
+	assert.Equal(t, filepath.Join(second, "src/github.com/yext/app/main.go"), stacktrace.GuessAbsPath("github.com/yext/app/main.go"),
+		"the first file which exists is used")
+	assert.Equal(t, filepath.Join(first, "github.com/yext/missing.go"), stacktrace.GuessAbsPath("github.com/yext/missing.go"),
+		"falls back to the first directory of the GOPATH")
+}
+
+func TestGuessAbsPathModules(t *testing.T) {
+	modcache := t.TempDir()
+	touch(t, filepath.Join(modcache, "github.com/yext/glog@v1.0.0/glog.go"))
+	t.Setenv("GOPATH", t.TempDir())
+	t.Setenv("GOMODCACHE", modcache)
+
+	assert.Equal(t, filepath.Join(modcache, "github.com/yext/glog@v1.0.0/glog.go"), stacktrace.GuessAbsPath("github.com/yext/glog@v1.0.0/glog.go"),
+		"dependencies are found in the module cache")
+
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(wd, "filesystem.go"), stacktrace.GuessAbsPath("github.com/yext/glog-contrib/stacktrace/filesystem.go"),
+		"files of the main module are found in its directory")
+}
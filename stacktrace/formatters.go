@@ -2,6 +2,7 @@ package stacktrace
 
 import (
 	"fmt"
+	"sort"
 
 	"github.com/getsentry/sentry-go"
 )
@@ -16,3 +17,27 @@ func SourceFromStack(s *sentry.Stacktrace) string {
 	f := s.Frames[len(s.Frames)-1]
 	return fmt.Sprintf("%s:%d", f.Function, f.Lineno)
 }
+
+// Fingerprint builds a fingerprint from the sorted, unique in-app frames of
+// the stack trace, each in the format "file in function at line 118". It is
+// shared by the raven and sentry backends, so that the same event is grouped
+// the same way by both.
+func Fingerprint(s *sentry.Stacktrace) []string {
+	if s == nil {
+		return nil
+	}
+
+	var r []string
+	seen := map[string]bool{}
+	for _, f := range s.Frames {
+		if f.InApp {
+			entry := fmt.Sprintf("%s in %s at line %d", f.Filename, f.Function, f.Lineno)
+			if !seen[entry] {
+				seen[entry] = true
+				r = append(r, entry)
+			}
+		}
+	}
+	sort.Strings(r)
+	return r
+}
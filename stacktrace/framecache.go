@@ -0,0 +1,115 @@
+package stacktrace
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/getsentry/sentry-go"
+)
+
+// The maximum number of stacks whose frames are cached. Errors are usually
+// logged from a small number of call sites, so this covers most of them.
+const maxFrameCacheSize = 1024
+
+// frameCache caches the frames resolved from the program counters of each
+// stack, since the same stacks are logged repeatedly and the program counters
+// of a process never change. The paths of the frames are resolved against
+// the environment (such as GOPATH) when they are first cached, which is
+// expected not to change while the process runs.
+var frameCache = newFramesLRU(maxFrameCacheSize)
+
+// framesLRU is a cache of resolved frames, which evicts the least recently
+// used stack when it is full.
+type framesLRU struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List
+	entries map[uint64]*list.Element
+}
+
+type framesEntry struct {
+	hash   uint64
+	pcs    []uintptr
+	frames []sentry.Frame
+}
+
+func newFramesLRU(size int) *framesLRU {
+	return &framesLRU{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[uint64]*list.Element),
+	}
+}
+
+// get returns a copy of the frames resolved from the program counters, if
+// they are cached.
+func (c *framesLRU) get(pcs []uintptr) ([]sentry.Frame, bool) {
+	h := hashPCs(pcs)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[h]
+	if !ok {
+		return nil, false
+	}
+	if e := el.Value.(*framesEntry); !equalPCs(e.pcs, pcs) {
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return append([]sentry.Frame(nil), el.Value.(*framesEntry).frames...), true
+}
+
+// add caches the frames resolved from the program counters.
+func (c *framesLRU) add(pcs []uintptr, frames []sentry.Frame) {
+	e := &framesEntry{
+		hash:   hashPCs(pcs),
+		pcs:    append([]uintptr(nil), pcs...),
+		frames: append([]sentry.Frame(nil), frames...),
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[e.hash]; ok {
+		el.Value = e
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[e.hash] = c.order.PushFront(e)
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*framesEntry).hash)
+	}
+}
+
+// clear removes all of the cached frames.
+func (c *framesLRU) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.order.Init()
+	c.entries = make(map[uint64]*list.Element)
+}
+
+// hashPCs hashes the program counters as FNV-1a does, a word at a time.
+func hashPCs(pcs []uintptr) uint64 {
+	const (
+		offset = 14695981039346656037
+		prime  = 1099511628211
+	)
+	h := uint64(offset)
+	for _, pc := range pcs {
+		h ^= uint64(pc)
+		h *= prime
+	}
+	return h
+}
+
+func equalPCs(a, b []uintptr) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
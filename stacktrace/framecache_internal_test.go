@@ -0,0 +1,64 @@
+package stacktrace
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func callers() []uintptr {
+	pcs := make([]uintptr, 50)
+	return pcs[:runtime.Callers(1, pcs)]
+}
+
+func TestExtractFramesCached(t *testing.T) {
+	frameCache.clear()
+	pcs := callers()
+	frames := extractFrames(pcs)
+	require.NotEmpty(t, frames)
+	assert.Equal(t, "callers", frames[len(frames)-1].Function, "the newest frame is last")
+
+	cached, ok := frameCache.get(pcs)
+	require.True(t, ok)
+	assert.Equal(t, frames, cached)
+
+	frames[0].Function = "modified"
+	assert.NotEqual(t, "modified", extractFrames(pcs)[0].Function, "the cached frames are copied")
+}
+
+func TestFramesLRU(t *testing.T) {
+	c := newFramesLRU(2)
+	c.add([]uintptr{1}, []sentry.Frame{{Function: "one"}})
+	c.add([]uintptr{2}, []sentry.Frame{{Function: "two"}})
+	_, ok := c.get([]uintptr{1})
+	require.True(t, ok)
+
+	c.add([]uintptr{3}, []sentry.Frame{{Function: "three"}})
+	_, ok = c.get([]uintptr{2})
+	assert.False(t, ok, "the least recently used stack is evicted")
+	frames, ok := c.get([]uintptr{1})
+	assert.True(t, ok)
+	assert.Equal(t, "one", frames[0].Function)
+	_, ok = c.get([]uintptr{1, 2})
+	assert.False(t, ok)
+}
+
+func BenchmarkExtractFrames(b *testing.B) {
+	pcs := callers()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		extractFrames(pcs)
+	}
+}
+
+func BenchmarkExtractFramesUncached(b *testing.B) {
+	pcs := callers()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		frameCache.clear()
+		extractFrames(pcs)
+	}
+}
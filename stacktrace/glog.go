@@ -0,0 +1,91 @@
+package stacktrace
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GlogHeader is the header which glog prepends to each message, in the form:
+//
+//	Lmmdd hh:mm:ss.uuuuuu file:line] msg...
+type GlogHeader struct {
+	// Severity is the full name of the log level, such as "ERROR".
+	Severity string
+	// Time is when the message was logged, in the local time zone. As the
+	// header does not include the year, the most recent matching year is used.
+	Time time.Time
+	// File is the base name of the file which logged the message.
+	File string
+	// Line is the line number which logged the message.
+	Line int
+}
+
+// The layout of the date and time in the glog header.
+const glogTimeLayout = "0102 15:04:05.000000"
+
+var glogSeverities = map[byte]string{
+	'I': "INFO",
+	'W': "WARNING",
+	'E': "ERROR",
+	'F': "FATAL",
+}
+
+// ParseGlogPrefix splits a message logged by glog into its header and body.
+// If the message does not begin with a well-formed glog header, it returns
+// a zero GlogHeader and the entire message as the body.
+func ParseGlogPrefix(msg []byte) (GlogHeader, string) {
+	message := string(msg)
+	header, n, ok := parseGlogHeader(message)
+	if !ok {
+		return GlogHeader{}, message
+	}
+	return header, message[n:]
+}
+
+// parseGlogHeader parses the glog header at the start of the message,
+// returning the header and its length.
+func parseGlogHeader(message string) (GlogHeader, int, bool) {
+	var header GlogHeader
+	n := len(glogTimeLayout) + 2
+	if len(message) < n || message[n-1] != ' ' {
+		return header, 0, false
+	}
+
+	var ok bool
+	if header.Severity, ok = glogSeverities[message[0]]; !ok {
+		return header, 0, false
+	}
+
+	t, err := time.ParseInLocation(glogTimeLayout, message[1:n-1], time.Local)
+	if err != nil {
+		return header, 0, false
+	}
+	header.Time = withRecentYear(t, time.Now())
+
+	end := strings.Index(message[n:], "] ")
+	if end == -1 {
+		return header, 0, false
+	}
+	location := message[n : n+end]
+	colon := strings.LastIndex(location, ":")
+	if colon == -1 {
+		return header, 0, false
+	}
+	header.File = location[:colon]
+	if header.Line, err = strconv.Atoi(location[colon+1:]); err != nil {
+		return header, 0, false
+	}
+
+	return header, n + end + 2, true
+}
+
+// withRecentYear sets the year of t to the most recent year in which it is
+// not after now (allowing for a day of clock skew).
+func withRecentYear(t, now time.Time) time.Time {
+	t = t.AddDate(now.Year()-t.Year(), 0, 0)
+	if t.After(now.AddDate(0, 0, 1)) {
+		t = t.AddDate(-1, 0, 0)
+	}
+	return t
+}
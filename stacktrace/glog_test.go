@@ -0,0 +1,40 @@
+package stacktrace_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yext/glog-contrib/stacktrace"
+)
+
+func TestParseGlogPrefix(t *testing.T) {
+	header, body := stacktrace.ParseGlogPrefix([]byte("E0102 15:04:05.678901 main.go:42] lookup failed: [id] 1"))
+	assert.Equal(t, "lookup failed: [id] 1", body)
+	assert.Equal(t, "ERROR", header.Severity)
+	assert.Equal(t, "main.go", header.File)
+	assert.Equal(t, 42, header.Line)
+	assert.Equal(t, time.January, header.Time.Month())
+	assert.Equal(t, 2, header.Time.Day())
+	assert.Equal(t, 15, header.Time.Hour())
+	assert.Equal(t, 678901000, header.Time.Nanosecond())
+	assert.False(t, header.Time.After(time.Now().AddDate(0, 0, 1)), "time is not in the future")
+	assert.True(t, header.Time.After(time.Now().AddDate(-1, 0, -1)), "time is within the last year")
+}
+
+func TestParseGlogPrefixHeaderless(t *testing.T) {
+	for _, msg := range []string{
+		"",
+		"lookup failed",
+		"lookup failed: [id] 1",
+		"X0102 15:04:05.678901 main.go:42] unknown severity",
+		"E0102 15:04:05 main.go:42] missing microseconds",
+		"E0102 15:04:05.678901 main.go] missing line",
+		"E0102 15:04:05.678901 main.go:42 missing bracket",
+	} {
+		header, body := stacktrace.ParseGlogPrefix([]byte(msg))
+		assert.Equal(t, stacktrace.GlogHeader{}, header, msg)
+		assert.Equal(t, msg, body, "message is unchanged")
+	}
+}
@@ -1,9 +1,11 @@
 package stacktrace
 
 import (
+	"go/build"
 	"reflect"
 	"runtime"
 	"strings"
+	"sync"
 
 	"github.com/getsentry/sentry-go"
 )
@@ -24,16 +26,7 @@ import (
 
 // ExtractStacktrace creates a new Stacktrace based on the given error.
 func ExtractStacktrace(err error) *sentry.Stacktrace {
-	method := extractReflectedStacktraceMethod(err)
-
-	var pcs []uintptr
-
-	if method.IsValid() {
-		pcs = extractPcs(method)
-	} else {
-		pcs = extractXErrorsPC(err)
-	}
-
+	pcs := extractErrorPcs(err)
 	if len(pcs) == 0 {
 		return nil
 	}
@@ -42,6 +35,88 @@ func ExtractStacktrace(err error) *sentry.Stacktrace {
 	return ExtractFrames(pcs, err)
 }
 
+// The most errors in a chain whose stack traces are merged.
+const maxChainDepth = 100
+
+// ExtractChainStacktrace is like ExtractStacktrace, but merges the stack
+// traces of each of the errors in the chain of wrapped errors (unwrapped by
+// Unwrap or Cause), such as when an error from github.com/pkg/errors is
+// wrapped several times. Of the errors joined by an Unwrap() []error method,
+// only the chain of the first is followed. The frames of each wrapping error which aren't
+// shared with the stack trace of the error it wraps are placed between the
+// frames where they diverge, so that the trace shows the full path of the
+// error.
+func ExtractChainStacktrace(err error) *sentry.Stacktrace {
+	top := err
+	var merged []uintptr
+	for depth := 0; err != nil && depth < maxChainDepth; depth++ {
+		if pcs := extractErrorPcs(err); len(pcs) > 0 {
+			merged = mergePcs(pcs, merged)
+		}
+		switch wrapper := err.(type) {
+		case interface{ Unwrap() error }:
+			err = wrapper.Unwrap()
+		case interface{ Unwrap() []error }:
+			err = firstError(wrapper.Unwrap())
+		case interface{ Cause() error }:
+			err = wrapper.Cause()
+		default:
+			err = nil
+		}
+	}
+	if len(merged) == 0 {
+		return nil
+	}
+	return ExtractFrames(merged, top)
+}
+
+// firstError returns the first of the errors which isn't nil, if any.
+func firstError(errs []error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergePcs merges the program counters of an error with those of the error
+// wrapping it, both of which are ordered from the newest call. The callers
+// they share (their common suffix) are only included once, and the frames of
+// the wrapping error follow the other frames of the wrapped error.
+func mergePcs(inner, outer []uintptr) []uintptr {
+	shared := 0
+	for shared < len(inner) && shared < len(outer) &&
+		inner[len(inner)-1-shared] == outer[len(outer)-1-shared] {
+		shared++
+	}
+	merged := make([]uintptr, 0, len(inner)+len(outer)-shared)
+	merged = append(merged, inner[:len(inner)-shared]...)
+	merged = append(merged, outer[:len(outer)-shared]...)
+	return append(merged, inner[len(inner)-shared:]...)
+}
+
+// extractErrorPcs returns the program counters of the stack trace recorded
+// by the error itself (but not any error it wraps), if it has one. Errors
+// which return their program counters directly are handled without
+// reflection, which is only used for the methods of libraries returning
+// their own types.
+func extractErrorPcs(err error) []uintptr {
+	switch e := err.(type) {
+	case nil:
+		return nil
+	case interface{ StackTrace() []uintptr }:
+		return e.StackTrace()
+	// https://github.com/go-errors/errors
+	case interface{ Callers() []uintptr }:
+		return e.Callers()
+	}
+	if method := extractReflectedStacktraceMethod(err); method.IsValid() {
+		return extractPcs(method)
+	}
+	return extractXErrorsPC(err)
+}
+
 // PATCH(jwoglom): ExtractFrames is split-out code from ExtractStacktrace
 // which allows for frame extraction to be performed given glog PC pointers.
 // The err argument is optional, if it is nil augmentation of xerror info is
@@ -68,46 +143,139 @@ func NewFrame(f runtime.Frame) sentry.Frame {
 
 // PATCH(jwoglom): fixes up the given frame
 func fixUpFrame(frame sentry.Frame) sentry.Frame {
-	// Without an absolute filesystem path for AbsPath,
-	// Sentry will not pull in neighboring code segments.
-	if frame.AbsPath != "" && !strings.HasPrefix(frame.AbsPath, "/") {
-		frame.AbsPath = GuessAbsPath(frame.AbsPath)
-	} else if frame.AbsPath == "" {
-		frame.AbsPath = GuessAbsPath(frame.Filename)
-	}
+	frame.AbsPath, frame.Filename = resolvePaths(frame.AbsPath, frame.Filename)
 
-	// Clean up the returned filename to remove the gopath
-	frame.Filename = GopathRelativeFile(frame.Filename)
+	frame.InApp = isInAppFrame(frame)
 
 	return frame
 }
 
+var (
+	inAppPrefixesMu sync.RWMutex
+	inAppPrefixes   []string
+)
+
+// SetInAppPrefixes sets the prefixes of the packages (such as
+// "github.com/yext/") whose frames are from the application. Frames from any
+// other package, or vendored into one of them, are then collapsed in Sentry.
+// By default (or if no prefixes are set), frames are from the application
+// unless they are in GOROOT, the module cache, or a vendor or third_party
+// directory.
+func SetInAppPrefixes(prefixes []string) {
+	inAppPrefixesMu.Lock()
+	defer inAppPrefixesMu.Unlock()
+	inAppPrefixes = append([]string(nil), prefixes...)
+	// Frames which were already resolved may no longer be in-app.
+	frameCache.clear()
+}
+
+// isInAppFrame returns whether the frame is from the application, rather than
+// the standard library or a third-party dependency, so that Sentry can
+// collapse the frames which are not.
+func isInAppFrame(frame sentry.Frame) bool {
+	inAppPrefixesMu.RLock()
+	prefixes := inAppPrefixes
+	inAppPrefixesMu.RUnlock()
+	if len(prefixes) > 0 {
+		return hasInAppPrefix(frame.Module, prefixes) && !isVendored(frame.Module)
+	}
+
+	if goroot := build.Default.GOROOT; goroot != "" && strings.HasPrefix(frame.AbsPath, goroot+"/") {
+		return false
+	}
+	for _, prefix := range []string{"GOROOT/", "external/"} {
+		if strings.HasPrefix(frame.AbsPath, prefix) {
+			return false
+		}
+	}
+	for _, dir := range []string{"/pkg/mod/", "/vendor/", "/third_party/"} {
+		if strings.Contains(frame.AbsPath, dir) {
+			return false
+		}
+	}
+	return !isVendored(frame.Module)
+}
+
+// hasInAppPrefix returns whether the package has one of the prefixes.
+func hasInAppPrefix(module string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(module, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// isVendored returns whether the package is a copy of a dependency.
+func isVendored(module string) bool {
+	return strings.Contains(module, "vendor") || strings.Contains(module, "third_party")
+}
+
+// packageName returns the package path of a qualified function name, such as
+// "github.com/org/repo/pkg" for "github.com/org/repo/pkg.(*T).Method".
+func packageName(function string) string {
+	slash := strings.LastIndex(function, "/") + 1
+	if dot := strings.Index(function[slash:], "."); dot != -1 {
+		return function[:slash+dot]
+	}
+	return ""
+}
+
 func extractFrames(pcs []uintptr) []sentry.Frame {
+	// Without any program counters, CallersFrames returns a single empty frame.
+	if len(pcs) == 0 {
+		return nil
+	}
+	if frames, ok := frameCache.get(pcs); ok {
+		return frames
+	}
+
 	var frames []sentry.Frame
 	callersFrames := runtime.CallersFrames(pcs)
-
 	for {
 		callerFrame, more := callersFrames.Next()
-
-		frames = append([]sentry.Frame{
-			NewFrame(callerFrame),
-		}, frames...)
-
+		frames = append(frames, NewFrame(callerFrame))
 		if !more {
 			break
 		}
 	}
+	// The oldest frame is first.
+	for i, j := 0, len(frames)-1; i < j; i, j = i+1, j-1 {
+		frames[i], frames[j] = frames[j], frames[i]
+	}
 
+	frameCache.add(pcs, frames)
 	return frames
 }
 
+var (
+	frameFilterMu sync.RWMutex
+	frameFilter   func(sentry.Frame) bool
+)
+
+// SetFrameFilter sets a function which returns whether a stack frame should
+// be reported, such as to hide the frames of an internal logging package so
+// that the innermost in-app frame is the real call site. It applies in
+// addition to the default filtering of frames internal to Go and the Sentry
+// SDK. Passing nil restores the default.
+func SetFrameFilter(keep func(sentry.Frame) bool) {
+	frameFilterMu.Lock()
+	defer frameFilterMu.Unlock()
+	frameFilter = keep
+}
+
 // filterFrames filters out stack frames that are not meant to be reported to
-// Sentry. Those are frames internal to the SDK or Go.
+// Sentry. Those are frames internal to the SDK or Go, and any excluded by
+// the filter set by SetFrameFilter.
 func filterFrames(frames []sentry.Frame) []sentry.Frame {
 	if len(frames) == 0 {
 		return nil
 	}
 
+	frameFilterMu.RLock()
+	keep := frameFilter
+	frameFilterMu.RUnlock()
+
 	filteredFrames := make([]sentry.Frame, 0, len(frames))
 
 	for _, frame := range frames {
@@ -121,6 +289,9 @@ func filterFrames(frames []sentry.Frame) []sentry.Frame {
 			!strings.HasSuffix(frame.Module, "_test") {
 			continue
 		}
+		if keep != nil && !keep(frame) {
+			continue
+		}
 		filteredFrames = append(filteredFrames, frame)
 	}
 
@@ -131,15 +302,15 @@ func extractReflectedStacktraceMethod(err error) reflect.Value {
 	var method reflect.Value
 
 	// https://github.com/pingcap/errors
-	methodGetStackTracer := reflect.ValueOf(err).MethodByName("GetStackTracer")
+	methodGetStackTracer := reflectedMethod(reflect.ValueOf(err), "GetStackTracer")
 	// https://github.com/pkg/errors
-	methodStackTrace := reflect.ValueOf(err).MethodByName("StackTrace")
+	methodStackTrace := reflectedMethod(reflect.ValueOf(err), "StackTrace")
 	// https://github.com/go-errors/errors
-	methodStackFrames := reflect.ValueOf(err).MethodByName("StackFrames")
+	methodStackFrames := reflectedMethod(reflect.ValueOf(err), "StackFrames")
 
 	if methodGetStackTracer.IsValid() {
 		stacktracer := methodGetStackTracer.Call(make([]reflect.Value, 0))[0]
-		stacktracerStackTrace := reflect.ValueOf(stacktracer).MethodByName("StackTrace")
+		stacktracerStackTrace := reflectedMethod(stacktracer, "StackTrace")
 
 		if stacktracerStackTrace.IsValid() {
 			method = stacktracerStackTrace
@@ -157,6 +328,26 @@ func extractReflectedStacktraceMethod(err error) reflect.Value {
 	return method
 }
 
+// reflectedMethod returns the named method of the value if it can be called
+// without arguments to return a value, or the zero Value otherwise, such as
+// if the value is a nil interface.
+func reflectedMethod(v reflect.Value, name string) reflect.Value {
+	if v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+	if !v.IsValid() || (v.Kind() == reflect.Ptr && v.IsNil()) {
+		return reflect.Value{}
+	}
+	method := v.MethodByName(name)
+	if !method.IsValid() {
+		return reflect.Value{}
+	}
+	if t := method.Type(); t.NumIn() != 0 || t.NumOut() == 0 {
+		return reflect.Value{}
+	}
+	return method
+}
+
 func extractPcs(method reflect.Value) []uintptr {
 	var pcs []uintptr
 
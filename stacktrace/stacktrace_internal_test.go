@@ -0,0 +1,22 @@
+package stacktrace
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergePcs(t *testing.T) {
+	for _, tc := range []struct {
+		name                 string
+		inner, outer, merged []uintptr
+	}{
+		{"shared callers", []uintptr{1, 2, 8, 9}, []uintptr{3, 8, 9}, []uintptr{1, 2, 3, 8, 9}},
+		{"no shared callers", []uintptr{1, 2}, []uintptr{3, 4}, []uintptr{1, 2, 3, 4}},
+		{"identical", []uintptr{1, 2}, []uintptr{1, 2}, []uintptr{1, 2}},
+		{"outer is a suffix", []uintptr{1, 2, 3}, []uintptr{2, 3}, []uintptr{1, 2, 3}},
+		{"no outer", []uintptr{1, 2}, nil, []uintptr{1, 2}},
+	} {
+		assert.Equal(t, tc.merged, mergePcs(tc.inner, tc.outer), tc.name)
+	}
+}
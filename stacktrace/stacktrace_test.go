@@ -0,0 +1,163 @@
+package stacktrace_test
+
+import (
+	"errors"
+	"runtime"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yext/glog-contrib/stacktrace"
+)
+
+func TestExtractFramesInApp(t *testing.T) {
+	// Capture the stack from within callbacks invoked by the standard library
+	// and a third-party module.
+	var pcs []uintptr
+	assert.Condition(t, func() bool {
+		sort.Slice([]int{2, 1}, func(i, j int) bool {
+			pcs = make([]uintptr, 50)
+			pcs = pcs[:runtime.Callers(1, pcs)]
+			return false
+		})
+		return true
+	})
+
+	trace := stacktrace.ExtractFrames(pcs, nil)
+	require.NotNil(t, trace)
+	frames := trace.Frames
+	require.NotEmpty(t, frames)
+
+	innermost := frames[len(frames)-1]
+	assert.Equal(t, "TestExtractFramesInApp.func1.1", innermost.Function, "the newest frame is last")
+	assert.True(t, innermost.InApp)
+
+	var sawStdlib, sawThirdParty, sawTest bool
+	for _, f := range frames {
+		switch {
+		case f.Module == "sort":
+			sawStdlib = true
+			assert.False(t, f.InApp, "standard library frames are not in app: %+v", f)
+		case strings.HasPrefix(f.Module, "github.com/stretchr/testify"):
+			sawThirdParty = true
+			assert.False(t, f.InApp, "third-party frames are not in app: %+v", f)
+		case f.Function == "TestExtractFramesInApp":
+			sawTest = true
+			assert.True(t, f.InApp, "application frames are in app: %+v", f)
+		}
+	}
+	assert.True(t, sawStdlib)
+	assert.True(t, sawThirdParty)
+	assert.True(t, sawTest)
+}
+
+// logError stands in for a logging wrapper, which adds a frame to the stack.
+func logError() []uintptr {
+	pcs := make([]uintptr, 50)
+	return pcs[:runtime.Callers(1, pcs)]
+}
+
+func TestSetFrameFilter(t *testing.T) {
+	pcs := logError()
+	frames := stacktrace.ExtractFrames(pcs, nil).Frames
+	require.NotEmpty(t, frames)
+	assert.Equal(t, "logError", frames[len(frames)-1].Function)
+
+	stacktrace.SetFrameFilter(func(f sentry.Frame) bool {
+		return f.Function != "logError"
+	})
+	defer stacktrace.SetFrameFilter(nil)
+
+	filtered := stacktrace.ExtractFrames(pcs, nil).Frames
+	require.Len(t, filtered, len(frames)-1)
+	assert.Equal(t, "TestSetFrameFilter", filtered[len(filtered)-1].Function, "the call site is the innermost frame")
+	for _, f := range filtered {
+		assert.NotEqual(t, "runtime", f.Module, "the default filter still applies")
+	}
+}
+
+func TestSetInAppPrefixes(t *testing.T) {
+	var pcs []uintptr
+	sort.Slice([]int{2, 1}, func(i, j int) bool {
+		pcs = make([]uintptr, 50)
+		pcs = pcs[:runtime.Callers(1, pcs)]
+		return false
+	})
+
+	stacktrace.SetInAppPrefixes([]string{"github.com/yext/glog-contrib/"})
+	defer stacktrace.SetInAppPrefixes(nil)
+
+	frames := stacktrace.ExtractFrames(pcs, nil).Frames
+	require.NotEmpty(t, frames)
+	for _, f := range frames {
+		assert.Equal(t, strings.HasPrefix(f.Module, "github.com/yext/glog-contrib/"), f.InApp, f.Module)
+	}
+	assert.True(t, frames[len(frames)-1].InApp, "frames with the prefix are in-app")
+	assert.False(t, frames[len(frames)-2].InApp, "frames without the prefix are not: "+frames[len(frames)-2].Module)
+}
+
+// stackError records a stack trace, as errors from github.com/pkg/errors do.
+type stackError struct {
+	msg   string
+	cause error
+	pcs   []uintptr
+}
+
+func newStackError(msg string, cause error) error {
+	pcs := make([]uintptr, 50)
+	return &stackError{msg, cause, pcs[:runtime.Callers(2, pcs)]}
+}
+
+func (e *stackError) Error() string         { return e.msg }
+func (e *stackError) Cause() error          { return e.cause }
+func (e *stackError) StackTrace() []uintptr { return e.pcs }
+
+func failingCall() error {
+	return newStackError("failed", nil)
+}
+
+func wrapError(err error) error {
+	return newStackError("wrapped", err)
+}
+
+func TestExtractChainStacktrace(t *testing.T) {
+	err := wrapError(failingCall())
+
+	functions := func(trace *sentry.Stacktrace) []string {
+		require.NotNil(t, trace)
+		var r []string
+		for _, f := range trace.Frames {
+			r = append(r, f.Function)
+		}
+		return r
+	}
+	assert.Equal(t, []string{"TestExtractChainStacktrace", "wrapError"}, functions(stacktrace.ExtractStacktrace(err)),
+		"only the stack trace of the outermost error")
+	assert.Equal(t, []string{"TestExtractChainStacktrace", "wrapError", "TestExtractChainStacktrace", "failingCall"}, functions(stacktrace.ExtractChainStacktrace(err)),
+		"the stack traces of each error, with the innermost call last")
+
+	assert.Nil(t, stacktrace.ExtractChainStacktrace(errors.New("no stack trace")))
+}
+
+// joinedErrors joins several errors, like errors.Join.
+type joinedErrors []error
+
+func (e joinedErrors) Error() string   { return "joined" }
+func (e joinedErrors) Unwrap() []error { return e }
+
+func TestExtractChainStacktraceJoined(t *testing.T) {
+	err := wrapError(joinedErrors{nil, failingCall(), errors.New("other")})
+
+	trace := stacktrace.ExtractChainStacktrace(err)
+	require.NotNil(t, trace)
+	var functions []string
+	for _, f := range trace.Frames {
+		functions = append(functions, f.Function)
+	}
+	assert.Equal(t, []string{"TestExtractChainStacktraceJoined", "wrapError", "TestExtractChainStacktraceJoined", "failingCall"}, functions,
+		"the chain of the first joined error is followed")
+}
@@ -4,7 +4,6 @@ import (
 	"golang.org/x/xerrors"
 
 	"github.com/getsentry/sentry-go"
-	"github.com/yext/glog"
 )
 
 // GetXErrorStackTrace returns a combined stack trace incorporating the stack of
@@ -25,15 +24,23 @@ func GetXErrorStackTrace(callSite sentry.Stacktrace, err error) *sentry.Stacktra
 	return &xs.trace
 }
 
+// The formats used by xerrors.Frame to write the function and location of a frame.
+const (
+	functionFormat = "%s\n    "
+	locationFormat = "%s:%d\n"
+)
+
 // xerrorsStack implements xerrors.Printer to capture only the wrapped stack trace.
 //
-// Exploits the fact that xerrors.Frame is always written as detail (and nothing else is, for any
-// known implementation).
+// Exploits the fact that xerrors.Frame is always written as detail, using a fixed pair of
+// format strings.
 //
 // It expects a sequence of alternating calls like this:
 //
 //   Printf("%s\n    ", []interface {}{"package.FuncName"})
 //   Printf("%s:%d\n", []interface {}{"/absolute/path/to/file.go", 47})
+//
+// interleaved with any other detail written by the error.
 type xerrorsStack struct {
 	detail bool
 	trace  sentry.Stacktrace
@@ -43,29 +50,33 @@ type xerrorsStack struct {
 func (x *xerrorsStack) Print(args ...interface{}) {}
 
 func (x *xerrorsStack) Printf(format string, args ...interface{}) {
-	if x.detail {
-		switch len(args) {
-		case 1:
-			if fn, ok := args[0].(string); ok {
-				x.fnName = fn
-			}
-		case 2:
-			var (
-				absPath, ok1 = args[0].(string)
-				lineno, ok2  = args[1].(int)
-			)
-			if !ok1 || !ok2 {
-				glog.Warningf("unexpected: Printf(%q, %#v)", format, args)
-				return
-			}
+	if !x.detail {
+		return
+	}
+	// Only the function and location written by xerrors.Frame are used. Any
+	// other detail (such as a custom message written by the FormatError method
+	// of an error) is ignored.
+	switch {
+	case format == functionFormat && len(args) == 1:
+		if fn, ok := args[0].(string); ok {
+			x.fnName = fn
+		}
+	case format == locationFormat && len(args) == 2:
+		var (
+			absPath, ok1 = args[0].(string)
+			lineno, ok2  = args[1].(int)
+		)
+		if ok1 && ok2 {
 			// fixUpFrame will clean up the Filename/AbsPath
 			x.trace.Frames = append(x.trace.Frames, fixUpFrame(sentry.Frame{
 				AbsPath:  absPath,
 				Filename: absPath,
 				Function: x.fnName,
+				Module:   packageName(x.fnName),
 				Lineno:   lineno,
 			}))
 		}
+		x.fnName = ""
 	}
 }
 
@@ -0,0 +1,45 @@
+package stacktrace_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/yext/yerrors"
+	"golang.org/x/xerrors"
+
+	"github.com/yext/glog-contrib/stacktrace"
+)
+
+// detailError writes custom detail lines around its frame.
+type detailError struct {
+	err   error
+	frame xerrors.Frame
+}
+
+func (e *detailError) Error() string {
+	return "detail error: " + e.err.Error()
+}
+
+func (e *detailError) FormatError(p xerrors.Printer) error {
+	p.Print("detail error")
+	if p.Detail() {
+		p.Printf("request %s for %s\n", "abc", "def")
+		e.frame.Format(p)
+		p.Printf("attempt %s #%d\n", "retry", 3)
+		p.Printf("%s\n", "trailing")
+	}
+	return e.err
+}
+
+func TestGetXErrorStackTraceCustomDetail(t *testing.T) {
+	err := &detailError{err: yerrors.New("test message"), frame: xerrors.Caller(0)}
+
+	trace := stacktrace.GetXErrorStackTrace(sentry.Stacktrace{}, err)
+	assert.Len(t, trace.Frames, 2, "one frame from each error, and none from the custom detail")
+	for _, fr := range trace.Frames {
+		assert.True(t, strings.HasSuffix(fr.Function, "TestGetXErrorStackTraceCustomDetail"), "function name has suffix: "+fr.Function)
+		assert.True(t, strings.HasSuffix(fr.AbsPath, "xerrors_test.go"), "abspath matches: "+fr.AbsPath)
+	}
+}